@@ -0,0 +1,31 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+	"simple-script-language/lexer"
+)
+
+// newCheckCmd 创建check命令, 只解析脚本并报告所有语法错误
+func newCheckCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "check <file>",
+		Short: "report every syntax error in a script file without running it",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			src, err := readSource(name)
+			if err != nil {
+				return &exitError{exitNoInput, err}
+			}
+			// 只检查不执行, 因此假定授予了所有能力, 使用文件、环境变量的函数不会报告为未定义
+			interp := lexer.NewInterpreter(lexer.Config{Grants: []lexer.Capability{
+				lexer.FileSystem("", true),
+				lexer.Environ(),
+			}})
+			if _, err := checkSource(name, src, interp.Globals()); err != nil {
+				return &exitError{exitSyntax, sourceError(err, src)}
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,59 @@
+// ssl 脚本语言命令行工具
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// 进程退出码(参照 sysexits.h)
+const (
+	exitOK      = 0  // 正常结束
+	exitUsage   = 64 // 命令行参数错误
+	exitSyntax  = 65 // 脚本语法错误
+	exitNoInput = 66 // 无法读取脚本文件
+	exitRuntime = 70 // 脚本运行时错误
+)
+
+// exitError 携带退出码的错误
+type exitError struct {
+	code int
+	err  error
+}
+
+// Error 实现error接口
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+// newRootCmd 创建根命令
+func newRootCmd() *cobra.Command {
+	root := &cobra.Command{
+		Use:           "ssl",
+		Short:         "simple script language",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		Args:          cobra.NoArgs,
+	}
+	repl := newReplCmd()
+	root.RunE = repl.RunE
+	root.Flags().AddFlagSet(repl.Flags())
+	root.AddCommand(newRunCmd(), newCheckCmd(), repl)
+	return root
+}
+
+func main() {
+	err := newRootCmd().Execute()
+	if err == nil {
+		os.Exit(exitOK)
+	}
+	fmt.Fprintf(os.Stderr, "ssl: %v\n", err)
+	var ee *exitError
+	if errors.As(err, &ee) {
+		os.Exit(ee.code)
+	}
+	os.Exit(exitUsage)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/peterh/liner"
+	"github.com/spf13/cobra"
+	"simple-script-language/lexer"
+)
+
+const (
+	replPrompt     = ">>> " // 提示符
+	replContPrompt = "... " // 多行输入时的提示符
+	historyFile    = ".ssl_history"
+)
+
+// newReplCmd 创建repl命令
+func newReplCmd() *cobra.Command {
+	var history string
+	cmd := &cobra.Command{
+		Use:   "repl",
+		Short: "start an interactive shell",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runRepl(history, cmd.OutOrStdout())
+		},
+	}
+	cmd.Flags().StringVar(&history, "history", defaultHistoryPath(), "history file")
+	return cmd
+}
+
+// defaultHistoryPath 默认的历史记录文件
+func defaultHistoryPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, historyFile)
+}
+
+// runRepl 交互式执行, 所有输入共享同一个全局环境
+func runRepl(history string, out io.Writer) error {
+	line := liner.NewLiner()
+	defer line.Close()
+	line.SetCtrlCAborts(true)
+	loadHistory(line, history)
+	defer saveHistory(line, history)
+
+	// spawn创建的任务在多次输入之间继续运行, 退出时取消
+	interp := lexer.NewInterpreter(lexer.Config{Out: out})
+	defer interp.Close()
+	// 保存每次输入的源码, 以便错误信息中显示之前输入中的源码行
+	sources := make(map[string]string)
+	var buf strings.Builder
+	for {
+		prompt := replPrompt
+		if buf.Len() > 0 {
+			prompt = replContPrompt
+		}
+		input, err := line.Prompt(prompt)
+		if err == liner.ErrPromptAborted {
+			buf.Reset()
+			continue
+		}
+		if err == io.EOF {
+			fmt.Fprintln(out)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(input) != "" {
+			line.AppendHistory(input)
+		}
+		buf.WriteString(input)
+		buf.WriteString("\n")
+		if blockDepth(buf.String()) > 0 {
+			continue
+		}
+		file := fmt.Sprintf("<stdin:%d>", len(sources)+1)
+		sources[file] = buf.String()
+		evalInput(file, sources, interp, out)
+		buf.Reset()
+	}
+}
+
+// evalInput 解析并执行一次输入, 打印每条语句的计算值
+func evalInput(file string, sources map[string]string, interp *lexer.Interpreter, out io.Writer) {
+	nodes, err := parseSource(file, sources[file])
+	if err != nil {
+		printReplError(err, sources, out)
+		return
+	}
+	for _, node := range nodes {
+		value, err := interp.Eval(context.Background(), []lexer.TreeNode{node})
+		if err != nil {
+			printReplError(err, sources, out)
+			return
+		}
+		fmt.Fprintf(out, "=> %v\n", lexer.ToString(value))
+	}
+}
+
+// printReplError 打印错误信息及出错的源码行
+func printReplError(err error, sources map[string]string, out io.Writer) {
+	var se *lexer.SyntaxError
+	var re *lexer.RuntimeError
+	file := ""
+	if errors.As(err, &se) {
+		file = se.File
+	} else if errors.As(err, &re) {
+		file = re.File
+	}
+	fmt.Fprintf(out, "error: %v\n", lexer.FormatError(err, sources[file]))
+}
+
+// blockDepth 计算尚未闭合的"{"个数
+func blockDepth(src string) int {
+	l := newSourceLexer("", src)
+	depth := 0
+	for {
+		t, err := l.Read()
+		if err != nil || t == lexer.EOF {
+			return depth
+		}
+		switch t.GetText() {
+		case "{":
+			depth++
+		case "}":
+			depth--
+		}
+	}
+}
+
+// loadHistory 读取历史记录
+func loadHistory(line *liner.State, path string) {
+	if path == "" {
+		return
+	}
+	if f, err := os.Open(path); err == nil {
+		line.ReadHistory(f)
+		f.Close()
+	}
+}
+
+// saveHistory 保存历史记录
+func saveHistory(line *liner.State, path string) {
+	if path == "" {
+		return
+	}
+	if f, err := os.Create(path); err == nil {
+		line.WriteHistory(f)
+		f.Close()
+	}
+}
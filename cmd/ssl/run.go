@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"simple-script-language/compiler"
+	"simple-script-language/lexer"
+	"simple-script-language/vm"
+)
+
+// runOptions run命令的参数
+type runOptions struct {
+	printAst    bool // 打印语法树
+	printTokens bool // 打印单词
+	printCode   bool // 打印字节码
+	useVM       bool // 编译为字节码后执行
+	limits      lexer.Limits
+	timeout     time.Duration // 执行超时, 为0时不限制
+	readDir     string        // 允许读取的目录
+	writeDir    string        // 允许读写的目录
+	allowEnv    bool          // 允许读取环境变量
+}
+
+// grants 命令行参数授予脚本的能力, 一个环境中只能授予一个目录, 因此不能同时指定--allow-read和--allow-write
+func (o *runOptions) grants() ([]lexer.Capability, error) {
+	if o.readDir != "" && o.writeDir != "" {
+		return nil, errors.New("--allow-read and --allow-write cannot be combined, --allow-write also allows reading")
+	}
+	var grants []lexer.Capability
+	if o.readDir != "" {
+		grants = append(grants, lexer.FileSystem(o.readDir, false))
+	}
+	if o.writeDir != "" {
+		grants = append(grants, lexer.FileSystem(o.writeDir, true))
+	}
+	if o.allowEnv {
+		grants = append(grants, lexer.Environ())
+	}
+	return grants, nil
+}
+
+// newRunCmd 创建run命令
+func newRunCmd() *cobra.Command {
+	opts := &runOptions{}
+	cmd := &cobra.Command{
+		Use:   "run <file>",
+		Short: "run a script file, use - to read from stdin",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runFile(args[0], opts, cmd.OutOrStdout())
+		},
+	}
+	cmd.Flags().BoolVar(&opts.printAst, "print-ast", false, "print the syntax tree instead of running")
+	cmd.Flags().BoolVar(&opts.printTokens, "print-tokens", false, "print the tokens instead of running")
+	cmd.Flags().BoolVar(&opts.printCode, "print-bytecode", false, "print the compiled bytecode instead of running")
+	cmd.Flags().BoolVar(&opts.useVM, "vm", false, "compile to bytecode and run on the virtual machine")
+	cmd.Flags().IntVar(&opts.limits.MaxSteps, "max-steps", 0, "maximum loop iterations and function calls, 0 for no limit")
+	cmd.Flags().IntVar(&opts.limits.MaxCallDepth, "max-depth", lexer.DefaultCallDepth, fmt.Sprintf("maximum function call depth, at most %v", lexer.CallDepthLimit))
+	cmd.Flags().IntVar(&opts.limits.MaxAllocations, "max-allocs", 0, "maximum allocation units: arrays, maps, functions, objects, elements built by builtins and each KiB of strings, 0 for no limit")
+	cmd.Flags().DurationVar(&opts.timeout, "timeout", 0, "stop the script after the given duration, 0 for no limit")
+	cmd.Flags().StringVar(&opts.readDir, "allow-read", "", "allow the script to read files in the directory")
+	cmd.Flags().StringVar(&opts.writeDir, "allow-write", "", "allow the script to read and write files in the directory")
+	cmd.Flags().BoolVar(&opts.allowEnv, "allow-env", false, "allow the script to read environment variables")
+	return cmd
+}
+
+// runFile 读取并执行脚本文件
+func runFile(name string, opts *runOptions, out io.Writer) error {
+	grants, err := opts.grants()
+	if err != nil {
+		return err
+	}
+	src, err := readSource(name)
+	if err != nil {
+		return &exitError{exitNoInput, err}
+	}
+	if opts.printTokens {
+		if err := printTokens(name, src, out); err != nil {
+			return &exitError{exitSyntax, sourceError(err, src)}
+		}
+	}
+	// 打印时只解析语法, 以便在变量解析出错时仍可以查看语法树
+	nodes, err := parseAll(name, src)
+	if err != nil {
+		return &exitError{exitSyntax, sourceError(err, src)}
+	}
+	if opts.printAst {
+		for _, node := range nodes {
+			fmt.Fprintln(out, node)
+		}
+	}
+	if opts.printAst || opts.printTokens {
+		return nil
+	}
+	ctx := context.Background()
+	if opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.timeout)
+		defer cancel()
+	}
+	interp := lexer.NewInterpreter(lexer.Config{Out: out, Limits: opts.limits, Grants: grants})
+	if opts.useVM || opts.printCode {
+		return runCompiled(ctx, nodes, src, opts, interp.Globals(), out)
+	}
+	// Eval执行前解析变量, 解析错误以ErrorList返回
+	_, err = interp.Eval(ctx, nodes)
+	// 结束时取消尚未结束的任务, 未被等待的任务出错时作为执行的错误
+	if taskErr := interp.Close(); err == nil {
+		err = taskErr
+	}
+	if _, ok := err.(lexer.ErrorList); ok {
+		return &exitError{exitSyntax, sourceError(err, src)}
+	}
+	if err != nil {
+		return &exitError{exitRuntime, sourceError(err, src)}
+	}
+	return nil
+}
+
+// runCompiled 解析变量后编译为字节码, 并在虚拟机中以env为全局环境执行, 执行受命令行参数的限制, ctx取消时停止
+func runCompiled(ctx context.Context, nodes []lexer.TreeNode, src string, opts *runOptions, env lexer.Environment, out io.Writer) error {
+	if err := lexer.Resolve(nodes, env); err != nil {
+		return &exitError{exitSyntax, sourceError(err, src)}
+	}
+	proto, err := compiler.Compile(nodes)
+	if err != nil {
+		return &exitError{exitSyntax, sourceError(err, src)}
+	}
+	if opts.printCode {
+		fmt.Fprint(out, proto)
+		return nil
+	}
+	if _, err := vm.New(env).RunContext(ctx, proto, opts.limits); err != nil {
+		return &exitError{exitRuntime, sourceError(err, src)}
+	}
+	return nil
+}
+
+// sourceError 附带源码行的错误信息, 多个语法错误时逐一显示
+func sourceError(err error, src string) error {
+	if list, ok := err.(lexer.ErrorList); ok {
+		msgs := make([]string, len(list))
+		for i, e := range list {
+			msgs[i] = lexer.FormatError(e, src)
+		}
+		return errors.New(strings.Join(msgs, "\n"))
+	}
+	return errors.New(lexer.FormatError(err, src))
+}
+
+// readSource 读取脚本内容, "-"表示标准输入
+func readSource(name string) (string, error) {
+	var data []byte
+	var err error
+	if name == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(name)
+	}
+	return string(data), err
+}
+
+// newSourceLexer 创建读取源码的词法分析器
+func newSourceLexer(name, src string) *lexer.Lexer {
+	return lexer.NewFileLexer(name, bufio.NewScanner(strings.NewReader(src)))
+}
+
+// printTokens 逐一打印单词
+func printTokens(name, src string, out io.Writer) error {
+	l := newSourceLexer(name, src)
+	for {
+		t, err := l.Read()
+		if err != nil {
+			return err
+		}
+		if t == lexer.EOF {
+			return nil
+		}
+		pos := t.GetPosition()
+		fmt.Fprintf(out, "%4d:%-4d %-10s %q\n", pos.Line, pos.Column, tokenKind(t), t.GetText())
+	}
+}
+
+// tokenKind 单词类型的名称
+func tokenKind(t lexer.Token) string {
+	switch {
+	case t.IsNumber():
+		return "number"
+	case t.IsString():
+		return "string"
+	case t.GetText() == lexer.EOL:
+		return "eol"
+	case t.IsIdentifier():
+		return "identifier"
+	}
+	return "unknown"
+}
+
+// parseSource 解析全部语句
+func parseSource(name, src string) ([]lexer.TreeNode, error) {
+	return lexer.Parse(name, strings.NewReader(src))
+}
+
+// parseAll 解析全部语句并报告所有语法错误, 不解析变量
+func parseAll(name, src string) ([]lexer.TreeNode, error) {
+	return lexer.ParseWithRecovery(name, strings.NewReader(src))
+}
+
+// checkSource 解析全部语句并报告所有语法错误, 包括变量在定义前使用等解析变量时发现的错误,
+// 全局变量及内置函数在env中查找
+func checkSource(name, src string, env lexer.Environment) ([]lexer.TreeNode, error) {
+	nodes, err := parseAll(name, src)
+	if err != nil {
+		return nodes, err
+	}
+	return nodes, lexer.Resolve(nodes, env)
+}
@@ -0,0 +1,562 @@
+// Package compiler 将语法树编译为字节码, 由vm包执行
+package compiler
+
+import (
+	"fmt"
+	"strings"
+
+	"simple-script-language/lexer"
+	"simple-script-language/utils/list"
+)
+
+// Proto 函数原型
+type Proto struct {
+	Name      string           // 函数名
+	Params    int              // 参数个数
+	Locals    int              // 局部变量个数(包含参数)
+	Cells     []int            // 被闭包捕获的局部变量, 调用时创建cell
+	Free      []FreeVar        // 闭包捕获的外层变量
+	Code      []Instruction    // 指令
+	Nodes     []lexer.TreeNode // 每条指令对应的语法树节点, 用于报告运行时错误
+	Constants []lexer.Value    // 常量池
+}
+
+// FreeVar 闭包捕获的变量, Local为true时为外层函数的第Index个局部变量, 否则为外层函数捕获的第Index个变量
+type FreeVar struct {
+	Local bool
+	Index int
+}
+
+// String 反汇编
+func (p *Proto) String() string {
+	var buf strings.Builder
+	p.disassemble(&buf)
+	return buf.String()
+}
+
+// disassemble 反汇编, 依次输出该函数及其中定义的函数
+func (p *Proto) disassemble(buf *strings.Builder) {
+	fmt.Fprintf(buf, "== %v (params: %v, locals: %v, cells: %v) ==\n", p.Name, p.Params, p.Locals, p.Cells)
+	for pc, ins := range p.Code {
+		fmt.Fprintf(buf, "%4d %v", pc, ins)
+		if ins.Op() == OpConst || ins.Op() == OpLoadGlobal || ins.Op() == OpStoreGlobal || ins.Op() == OpDefineGlobal {
+			fmt.Fprintf(buf, " (%v)", lexer.ToString(p.Constants[ins.Arg()]))
+		}
+		buf.WriteString("\n")
+	}
+	for _, c := range p.Constants {
+		if f, ok := c.(*Proto); ok {
+			f.disassemble(buf)
+		}
+	}
+}
+
+// scope 函数的作用域, 顶层(main)的变量均为全局变量
+type scope struct {
+	parent   *scope
+	proto    *Proto
+	locals   map[string]int // 变量名 -> 局部变量下标
+	captured map[int]bool   // 被内层函数捕获的局部变量
+	free     map[string]int // 变量名 -> 捕获变量下标
+	global   bool           // 是否为顶层
+	loops    []*loop        // 当前所在的循环
+}
+
+// loop 循环中break、continue的跳转位置
+type loop struct {
+	start  int   // continue跳转的位置
+	breaks []int // 待回填的break跳转指令
+}
+
+// Compiler 编译器
+type Compiler struct {
+	globals map[string]bool            // 顶层定义的变量
+	scopes  map[*list.ArrayList]*scope // 函数节点 -> 作用域, 以子节点列表区分节点
+	scope   *scope                     // 当前作用域
+}
+
+// Compile 编译语法树, 返回顶层代码的函数原型
+func Compile(nodes []lexer.TreeNode) (proto *Proto, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*lexer.SyntaxError)
+			if !ok {
+				panic(r)
+			}
+			proto, err = nil, e
+		}
+	}()
+	c := &Compiler{
+		globals: make(map[string]bool),
+		scopes:  make(map[*list.ArrayList]*scope),
+	}
+	for _, node := range nodes {
+		c.collectGlobals(node)
+	}
+	// 第一遍找出被内层函数捕获的局部变量, 第二遍以此生成指令
+	c.compileMain(nodes)
+	return c.compileMain(nodes), nil
+}
+
+// compileMain 编译顶层代码
+func (c *Compiler) compileMain(nodes []lexer.TreeNode) *Proto {
+	c.scope = &scope{proto: &Proto{Name: "<main>"}, global: true}
+	count := 0
+	for _, node := range nodes {
+		if _, ok := node.(lexer.NullStatementNode); ok {
+			continue
+		}
+		if count > 0 {
+			c.emit(OpPop, 0, nil)
+		}
+		c.compile(node)
+		count++
+	}
+	if count == 0 {
+		c.emit(OpNil, 0, nil)
+	}
+	c.emit(OpReturn, 0, nil)
+	return c.scope.proto
+}
+
+// collectGlobals 收集顶层赋值和定义的变量名, 不进入函数内部
+func (c *Compiler) collectGlobals(node lexer.TreeNode) {
+	for _, name := range declaredNames(node) {
+		c.globals[name] = true
+	}
+}
+
+// declaredNames 获取节点中(不包括内层函数)赋值或定义的变量名
+func declaredNames(node lexer.TreeNode) []string {
+	var names []string
+	var walk func(n lexer.TreeNode)
+	walk = func(n lexer.TreeNode) {
+		switch v := n.(type) {
+		case lexer.FunNode:
+			return
+		case lexer.DefStatementNode:
+			names = append(names, v.Name())
+			return
+		case lexer.BinaryExprNode:
+			if left, ok := v.Left().(lexer.VariableNode); ok && v.Operator() == "=" {
+				names = append(names, left.Name())
+			}
+		}
+		n.Children().For(func(k int, child interface{}) {
+			walk(child.(lexer.TreeNode))
+		})
+	}
+	walk(node)
+	return names
+}
+
+// errorf 抛出编译错误
+func errorf(node lexer.TreeNode, format string, a ...interface{}) {
+	span := node.Span()
+	panic(&lexer.SyntaxError{Position: span.Start, End: span.End, Msg: fmt.Sprintf(format, a...)})
+}
+
+// emit 生成指令, 返回指令的位置
+func (c *Compiler) emit(op Opcode, arg int, node lexer.TreeNode) int {
+	if arg > maxArg {
+		errorf(node, "too many constants or instructions")
+	}
+	p := c.scope.proto
+	p.Code = append(p.Code, MakeInstruction(op, arg))
+	p.Nodes = append(p.Nodes, node)
+	return len(p.Code) - 1
+}
+
+// patch 将位置pc处跳转指令的目标设置为当前位置
+func (c *Compiler) patch(pc int) {
+	p := c.scope.proto
+	p.Code[pc] = MakeInstruction(p.Code[pc].Op(), len(p.Code))
+}
+
+// constant 添加常量, 返回常量下标
+func (c *Compiler) constant(v lexer.Value) int {
+	p := c.scope.proto
+	for i, k := range p.Constants {
+		if _, ok := k.(*Proto); !ok && k == v {
+			return i
+		}
+	}
+	p.Constants = append(p.Constants, v)
+	return len(p.Constants) - 1
+}
+
+// resolve 解析变量, 返回变量所在位置的加载和保存指令及操作数
+func (c *Compiler) resolve(name string) (load Opcode, store Opcode, arg int) {
+	s := c.scope
+	if s.global {
+		return OpLoadGlobal, OpStoreGlobal, c.constant(name)
+	}
+	if slot, ok := s.locals[name]; ok {
+		if s.captured[slot] {
+			return OpLoadCell, OpStoreCell, slot
+		}
+		return OpLoadLocal, OpStoreLocal, slot
+	}
+	if index, ok := c.capture(s, name); ok {
+		return OpLoadFree, OpStoreFree, index
+	}
+	return OpLoadGlobal, OpStoreGlobal, c.constant(name)
+}
+
+// capture 在外层函数中查找变量, 找到时在s中添加捕获变量并返回其下标
+func (c *Compiler) capture(s *scope, name string) (int, bool) {
+	if index, ok := s.free[name]; ok {
+		return index, true
+	}
+	parent := s.parent
+	if parent == nil || parent.global {
+		return 0, false
+	}
+	var fv FreeVar
+	if slot, ok := parent.locals[name]; ok {
+		parent.captured[slot] = true
+		fv = FreeVar{Local: true, Index: slot}
+	} else if index, ok := c.capture(parent, name); ok {
+		fv = FreeVar{Local: false, Index: index}
+	} else {
+		return 0, false
+	}
+	s.proto.Free = append(s.proto.Free, fv)
+	s.free[name] = len(s.proto.Free) - 1
+	return s.free[name], true
+}
+
+// declared 变量是否已在外层函数中定义
+func (c *Compiler) declared(s *scope, name string) bool {
+	for p := s.parent; p != nil && !p.global; p = p.parent {
+		if _, ok := p.locals[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// compileFunction 编译函数, 函数中赋值的变量在外层函数和全局均未定义时为局部变量,
+// 参数和函数中定义的函数总是局部变量
+func (c *Compiler) compileFunction(node lexer.TreeNode, name string, params lexer.ParameterListNode, body lexer.BlockStatementNode) {
+	s := &scope{
+		parent:   c.scope,
+		proto:    &Proto{Name: name, Params: params.Size()},
+		locals:   make(map[string]int),
+		captured: make(map[int]bool),
+		free:     make(map[string]int),
+	}
+	// 生成指令时沿用分析阶段得到的被捕获变量
+	if old, ok := c.scopes[node.Children()]; ok {
+		s.captured = old.captured
+	}
+	c.scopes[node.Children()] = s
+	for i := 0; i < params.Size(); i++ {
+		s.locals[params.Name(i)] = i
+	}
+	def := make(map[string]bool)
+	body.Children().For(func(k int, v interface{}) {
+		if d, ok := v.(lexer.DefStatementNode); ok {
+			def[d.Name()] = true
+		}
+	})
+	for _, n := range declaredNames(body) {
+		if _, ok := s.locals[n]; ok {
+			continue
+		}
+		if !def[n] && (c.declared(s, n) || c.globals[n]) {
+			continue
+		}
+		s.locals[n] = len(s.locals)
+	}
+	s.proto.Locals = len(s.locals)
+
+	outer := c.scope
+	c.scope = s
+	c.compileBlock(body)
+	c.emit(OpReturn, 0, nil)
+	c.scope = outer
+
+	s.proto.Cells = s.proto.Cells[:0]
+	for slot := 0; slot < s.proto.Locals; slot++ {
+		if s.captured[slot] {
+			s.proto.Cells = append(s.proto.Cells, slot)
+		}
+	}
+	c.emit(OpClosure, c.constant(s.proto), node)
+}
+
+// compileBlock 编译代码块, 值为最后一条语句的值
+func (c *Compiler) compileBlock(block lexer.TreeNode) {
+	count := 0
+	block.Children().For(func(k int, v interface{}) {
+		if _, ok := v.(lexer.NullStatementNode); ok {
+			return
+		}
+		if count > 0 {
+			c.emit(OpPop, 0, nil)
+		}
+		c.compile(v.(lexer.TreeNode))
+		count++
+	})
+	if count == 0 {
+		c.emit(OpNil, 0, nil)
+	}
+}
+
+// compile 编译语句或表达式, 执行后栈顶增加一个值
+func (c *Compiler) compile(node lexer.TreeNode) {
+	switch n := node.(type) {
+	case lexer.NumberNode, lexer.StringNode:
+		c.emit(OpConst, c.constant(n.Eval(nil)), n)
+	case lexer.BoolNode:
+		if n.Eval(nil) == true {
+			c.emit(OpTrue, 0, n)
+		} else {
+			c.emit(OpFalse, 0, n)
+		}
+	case lexer.NilNode, lexer.NullStatementNode:
+		c.emit(OpNil, 0, n)
+	case lexer.VariableNode:
+		load, _, arg := c.resolve(n.Name())
+		c.emit(load, arg, n)
+	case lexer.BinaryExprNode:
+		c.compileBinary(n)
+	case lexer.NegativeExprNode:
+		c.compile(n.Operand())
+		c.emit(OpNeg, 0, n)
+	case lexer.NotExprNode:
+		c.compile(n.Operand())
+		c.emit(OpNot, 0, n)
+	case lexer.PrimaryExpr:
+		c.compilePrimary(n, n.ChildSize())
+	case lexer.ArrayLiteralNode:
+		n.Children().For(func(k int, v interface{}) {
+			c.compile(v.(lexer.TreeNode))
+		})
+		c.emit(OpArray, n.Size(), n)
+	case lexer.MapLiteralNode:
+		n.Children().For(func(k int, v interface{}) {
+			entry := v.(lexer.MapEntryNode)
+			c.compile(entry.Key())
+			c.compile(entry.Value())
+		})
+		c.emit(OpMap, n.Size()*2, n)
+	case lexer.BlockStatementNode:
+		c.compileBlock(n)
+	case lexer.IfStatementNode:
+		c.compile(n.Condition())
+		jumpElse := c.emit(OpJumpIfFalse, 0, n)
+		c.compile(n.ThenBlock())
+		jumpEnd := c.emit(OpJump, 0, n)
+		c.patch(jumpElse)
+		if e := n.ElseBlock(); e != nil {
+			c.compile(e)
+		} else {
+			c.emit(OpNil, 0, n)
+		}
+		c.patch(jumpEnd)
+	case lexer.WhileStatementNode:
+		c.compileWhile(n)
+	case lexer.BreakStatementNode:
+		l := c.currentLoop(n)
+		l.breaks = append(l.breaks, c.emit(OpJump, 0, n))
+	case lexer.ContinueStatementNode:
+		c.emit(OpJump, c.currentLoop(n).start, n)
+	case lexer.ReturnStatementNode:
+		if c.scope.global {
+			errorf(n, "return outside function")
+		}
+		if v := n.Value(); v != nil {
+			c.compile(v)
+		} else {
+			c.emit(OpNil, 0, n)
+		}
+		c.emit(OpReturn, 0, n)
+	case lexer.DefStatementNode:
+		c.compileFunction(n, n.Name(), n.Parameters(), n.Body())
+		if c.scope.global {
+			c.emit(OpDefineGlobal, c.constant(n.Name()), n)
+		} else {
+			_, store, arg := c.resolve(n.Name())
+			c.emit(store, arg, n)
+		}
+		c.emit(OpConst, c.constant(n.Name()), n)
+	case lexer.FunNode:
+		c.compileFunction(n, "<fun>", n.Parameters(), n.Body())
+	case lexer.DelStatementNode:
+		c.compileDel(n)
+	case lexer.ClassStatementNode:
+		errorf(n, "class is not supported by the compiler")
+	case lexer.DeclStatementNode:
+		errorf(n, "%v is not supported by the compiler", n.Kind())
+	case lexer.ForInStatementNode, lexer.ForStatementNode:
+		errorf(n, "for is not supported by the compiler")
+	case lexer.ThrowStatementNode:
+		errorf(n, "throw is not supported by the compiler")
+	case lexer.TryStatementNode:
+		errorf(n, "try is not supported by the compiler")
+	case lexer.SpawnNode:
+		errorf(n, "spawn is not supported by the compiler")
+	case lexer.ErrorNode:
+		panic(n.Err())
+	default:
+		errorf(node, "cannot compile %v", node)
+	}
+}
+
+// compileBinary 编译二元表达式
+func (c *Compiler) compileBinary(n lexer.BinaryExprNode) {
+	switch op := n.Operator(); op {
+	case "=":
+		c.compileAssign(n)
+	case "&&", "||":
+		// 短路求值, 结果为bool
+		c.compile(n.Left())
+		jump := OpJumpIfFalse
+		if op == "||" {
+			jump = OpJumpIfTrue
+		}
+		shortCut := c.emit(jump, 0, n)
+		c.compile(n.Right())
+		c.emit(OpTruthy, 0, n)
+		end := c.emit(OpJump, 0, n)
+		c.patch(shortCut)
+		if op == "||" {
+			c.emit(OpTrue, 0, n)
+		} else {
+			c.emit(OpFalse, 0, n)
+		}
+		c.patch(end)
+	default:
+		c.compile(n.Left())
+		c.compile(n.Right())
+		bin, ok := binaryOps[op]
+		if !ok {
+			bin = BinOther
+		}
+		c.emit(OpBinary, bin, n)
+	}
+}
+
+// compileAssign 编译赋值, 左边为变量、数组元素或成员
+func (c *Compiler) compileAssign(n lexer.BinaryExprNode) {
+	switch left := n.Left().(type) {
+	case lexer.VariableNode:
+		c.compile(n.Right())
+		c.emit(OpDup, 0, n)
+		_, store, arg := c.resolve(left.Name())
+		c.emit(store, arg, left)
+		return
+	case lexer.PrimaryExpr:
+		switch postfix := left.Postfix(0).(type) {
+		case lexer.ArrayRefNode:
+			if _, slice := postfix.Slice(); !slice {
+				c.compilePrimary(left, left.ChildSize()-1)
+				c.compile(postfix.Index())
+				c.compile(n.Right())
+				c.emit(OpSetIndex, 0, postfix)
+				return
+			}
+		case lexer.DotNode:
+			c.compilePrimary(left, left.ChildSize()-1)
+			c.compile(n.Right())
+			c.emit(OpSetField, 0, postfix)
+			return
+		}
+	}
+	errorf(n, "bad assignment")
+}
+
+// compilePrimary 编译操作数及前size-1个后缀
+func (c *Compiler) compilePrimary(p lexer.PrimaryExpr, size int) {
+	c.compile(p.Operand())
+	for i := 1; i < size; i++ {
+		child, _ := p.Child(i)
+		switch postfix := child.(type) {
+		case lexer.ArgumentsNode:
+			postfix.Children().For(func(k int, v interface{}) {
+				c.compile(v.(lexer.TreeNode))
+			})
+			var node lexer.TreeNode = postfix
+			if postfix.Size() == 0 {
+				node = p
+			}
+			c.emit(OpCall, postfix.Size(), node)
+		case lexer.ArrayRefNode:
+			s, slice := postfix.Slice()
+			if postfix.ChildSize() > 2 || (postfix.ChildSize() == 2 && postfix.Index() == nil) {
+				errorf(postfix, "bad slice")
+			}
+			if !slice {
+				c.compile(postfix.Index())
+				c.emit(OpIndex, 0, postfix)
+				continue
+			}
+			c.compileOptional(postfix.Index())
+			c.compileOptional(s.End())
+			c.emit(OpSlice, 0, postfix)
+		case lexer.DotNode:
+			c.emit(OpGetField, 0, postfix)
+		default:
+			errorf(child, "cannot compile %v", child)
+		}
+	}
+}
+
+// compileOptional 编译可省略的表达式, 省略时为nil
+func (c *Compiler) compileOptional(node lexer.TreeNode) {
+	if node == nil {
+		c.emit(OpNil, 0, nil)
+	} else {
+		c.compile(node)
+	}
+}
+
+// compileWhile 编译while循环, 值为最后一次执行循环体的值
+func (c *Compiler) compileWhile(n lexer.WhileStatementNode) {
+	c.emit(OpNil, 0, n)
+	l := &loop{start: len(c.scope.proto.Code)}
+	c.compile(n.Condition())
+	exit := c.emit(OpJumpIfFalse, 0, n)
+	c.scope.loops = append(c.scope.loops, l)
+	c.compile(n.Body())
+	c.scope.loops = c.scope.loops[:len(c.scope.loops)-1]
+	// 以循环体的值替换上一次的值
+	c.emit(OpSwap, 0, n)
+	c.emit(OpPop, 0, n)
+	c.emit(OpJump, l.start, n)
+	c.patch(exit)
+	for _, b := range l.breaks {
+		c.patch(b)
+	}
+}
+
+// currentLoop 当前所在的循环
+func (c *Compiler) currentLoop(node lexer.TreeNode) *loop {
+	loops := c.scope.loops
+	if len(loops) == 0 {
+		errorf(node, "%v outside loop", node)
+	}
+	return loops[len(loops)-1]
+}
+
+// compileDel 编译del语句
+func (c *Compiler) compileDel(n lexer.DelStatementNode) {
+	if p, ok := n.Target().(lexer.PrimaryExpr); ok {
+		switch postfix := p.Postfix(0).(type) {
+		case lexer.ArrayRefNode:
+			if _, slice := postfix.Slice(); !slice {
+				c.compilePrimary(p, p.ChildSize()-1)
+				c.compile(postfix.Index())
+				c.emit(OpDelIndex, 0, postfix)
+				return
+			}
+		case lexer.DotNode:
+			c.compilePrimary(p, p.ChildSize()-1)
+			c.emit(OpDelField, 0, postfix)
+			return
+		}
+	}
+	errorf(n, "bad del target")
+}
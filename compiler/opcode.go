@@ -0,0 +1,146 @@
+package compiler
+
+import "fmt"
+
+// Opcode 操作码
+type Opcode uint8
+
+const (
+	OpConst        Opcode = iota // 压入常量池中第A个常量
+	OpNil                        // 压入nil
+	OpTrue                       // 压入true
+	OpFalse                      // 压入false
+	OpPop                        // 弹出栈顶
+	OpDup                        // 复制栈顶
+	OpSwap                       // 交换栈顶的两个值
+	OpLoadLocal                  // 压入第A个局部变量
+	OpStoreLocal                 // 弹出栈顶并保存到第A个局部变量
+	OpLoadCell                   // 压入第A个局部变量(被闭包捕获, 保存在cell中)的值
+	OpStoreCell                  // 弹出栈顶并保存到第A个局部变量的cell中
+	OpLoadFree                   // 压入闭包捕获的第A个变量
+	OpStoreFree                  // 弹出栈顶并保存到闭包捕获的第A个变量
+	OpLoadGlobal                 // 压入名为常量A的全局变量
+	OpStoreGlobal                // 弹出栈顶并赋值给名为常量A的全局变量
+	OpDefineGlobal               // 弹出栈顶并在全局环境中定义名为常量A的变量
+	OpBinary                     // 弹出右、左操作数, 以操作符A计算并压入结果
+	OpNeg                        // 取相反数
+	OpNot                        // 逻辑非
+	OpTruthy                     // 将栈顶转换为bool
+	OpJump                       // 跳转至A
+	OpJumpIfFalse                // 弹出栈顶, 为假时跳转至A
+	OpJumpIfTrue                 // 弹出栈顶, 为真时跳转至A
+	OpCall                       // 以A个实参调用函数
+	OpReturn                     // 返回栈顶
+	OpClosure                    // 以常量A中的函数原型创建闭包
+	OpArray                      // 以栈顶A个值创建数组
+	OpMap                        // 以栈顶A个值(键、值交替)创建映射
+	OpIndex                      // 弹出下标和数组, 压入元素
+	OpSlice                      // 弹出结束、起始下标和数组, 压入切片
+	OpSetIndex                   // 弹出值、下标和数组, 设置元素并压入值
+	OpDelIndex                   // 弹出下标和数组, 删除元素并压入被删除的值
+	OpGetField                   // 弹出对象, 压入成员
+	OpSetField                   // 弹出值和对象, 设置成员并压入值
+	OpDelField                   // 弹出对象, 删除成员并压入被删除的值
+)
+
+var opNames = [...]string{
+	OpConst:        "CONST",
+	OpNil:          "NIL",
+	OpTrue:         "TRUE",
+	OpFalse:        "FALSE",
+	OpPop:          "POP",
+	OpDup:          "DUP",
+	OpSwap:         "SWAP",
+	OpLoadLocal:    "LOAD_LOCAL",
+	OpStoreLocal:   "STORE_LOCAL",
+	OpLoadCell:     "LOAD_CELL",
+	OpStoreCell:    "STORE_CELL",
+	OpLoadFree:     "LOAD_FREE",
+	OpStoreFree:    "STORE_FREE",
+	OpLoadGlobal:   "LOAD_GLOBAL",
+	OpStoreGlobal:  "STORE_GLOBAL",
+	OpDefineGlobal: "DEFINE_GLOBAL",
+	OpBinary:       "BINARY",
+	OpNeg:          "NEG",
+	OpNot:          "NOT",
+	OpTruthy:       "TRUTHY",
+	OpJump:         "JUMP",
+	OpJumpIfFalse:  "JUMP_IF_FALSE",
+	OpJumpIfTrue:   "JUMP_IF_TRUE",
+	OpCall:         "CALL",
+	OpReturn:       "RETURN",
+	OpClosure:      "CLOSURE",
+	OpArray:        "ARRAY",
+	OpMap:          "MAP",
+	OpIndex:        "INDEX",
+	OpSlice:        "SLICE",
+	OpSetIndex:     "SET_INDEX",
+	OpDelIndex:     "DEL_INDEX",
+	OpGetField:     "GET_FIELD",
+	OpSetField:     "SET_FIELD",
+	OpDelField:     "DEL_FIELD",
+}
+
+// String 操作码名称
+func (o Opcode) String() string {
+	if int(o) < len(opNames) {
+		return opNames[o]
+	}
+	return fmt.Sprintf("OP(%d)", o)
+}
+
+// 二元操作符, 作为OpBinary的操作数
+const (
+	BinAdd = iota
+	BinSub
+	BinMul
+	BinDiv
+	BinMod
+	BinEq
+	BinNe
+	BinLt
+	BinGt
+	BinLe
+	BinGe
+	BinOther // 其他操作符, 如in
+)
+
+var binaryOps = map[string]int{
+	"+":  BinAdd,
+	"-":  BinSub,
+	"*":  BinMul,
+	"/":  BinDiv,
+	"%":  BinMod,
+	"==": BinEq,
+	"!=": BinNe,
+	"<":  BinLt,
+	">":  BinGt,
+	"<=": BinLe,
+	">=": BinGe,
+}
+
+// maxArg 操作数的最大值
+const maxArg = 1<<24 - 1
+
+// Instruction 指令, 低8位为操作码, 高24位为操作数
+type Instruction uint32
+
+// MakeInstruction 创建指令
+func MakeInstruction(op Opcode, arg int) Instruction {
+	return Instruction(uint32(arg)<<8 | uint32(op))
+}
+
+// Op 操作码
+func (i Instruction) Op() Opcode {
+	return Opcode(i & 0xff)
+}
+
+// Arg 操作数
+func (i Instruction) Arg() int {
+	return int(i >> 8)
+}
+
+// String 实现String接口
+func (i Instruction) String() string {
+	return fmt.Sprintf("%-14v %v", i.Op(), i.Arg())
+}
@@ -7,11 +7,11 @@ require (
 	github.com/go-delve/delve v1.5.1 // indirect
 	github.com/mattn/go-colorable v0.1.8 // indirect
 	github.com/mattn/go-runewidth v0.0.9 // indirect
-	github.com/peterh/liner v1.2.1 // indirect
+	github.com/peterh/liner v1.2.1
 	github.com/russross/blackfriday v1.6.0 // indirect
 	github.com/russross/blackfriday/v2 v2.1.0 // indirect
 	github.com/sirupsen/logrus v1.7.0 // indirect
-	github.com/spf13/cobra v1.1.1 // indirect
+	github.com/spf13/cobra v1.1.1
 	go.starlark.net v0.0.0-20201210151846-e81fc95f7bd5 // indirect
 	golang.org/x/arch v0.0.0-20201207233722-1e68675e650f // indirect
 	golang.org/x/sys v0.0.0-20201218084310-7d0127a74742 // indirect
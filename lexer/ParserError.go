@@ -4,14 +4,26 @@ import "fmt"
 
 // ParserError 解析错误
 func ParserError(msg string, token Token) {
-	panic(fmt.Sprintf("syntax error around %v. %v", errorLocation(token), msg))
+	panic(newSyntaxError(msg, token))
+}
+
+// newSyntaxError 创建指定单词处的语法错误
+func newSyntaxError(msg string, token Token) *SyntaxError {
+	text := fmt.Sprintf("syntax error around %v", errorLocation(token))
+	if msg != "" {
+		text += ". " + msg
+	}
+	span := token.GetSpan()
+	return &SyntaxError{Position: span.Start, End: span.End, Token: token, Msg: text}
 }
 
 // errorLocation 错误定位信息
 func errorLocation(token Token) string {
 	if token == EOF {
 		return "the last line"
+	} else if token.GetText() == EOL {
+		return "the end of line"
 	} else {
-		return fmt.Sprintf(`"%v" at line %v`, token.GetText(), token.GetLineNumber())
+		return fmt.Sprintf(`"%v"`, token.GetText())
 	}
 }
@@ -0,0 +1,97 @@
+package lexer
+
+import (
+	"bufio"
+	"context"
+	"io"
+)
+
+// Parse 解析全部源码, 语法错误以*SyntaxError返回
+func Parse(file string, reader io.Reader) (nodes []TreeNode, err error) {
+	lexer := NewFileLexer(file, bufio.NewScanner(reader))
+	defer func() {
+		if r := recover(); r != nil {
+			nodes, err = nil, RecoveredError(r)
+			if se, ok := err.(*SyntaxError); ok {
+				lexer.locate(se)
+			}
+		}
+	}()
+	parser := NewSpawnParser()
+	for {
+		t, err := lexer.Peek(0)
+		if err != nil {
+			return nil, err
+		}
+		if t == EOF {
+			return nodes, nil
+		}
+		node := parser.Parser(lexer)
+		if _, ok := node.(NullStatementNode); !ok {
+			nodes = append(nodes, node)
+		}
+	}
+}
+
+// ParseWithRecovery 解析全部源码, 出错后跳过出错的语句继续解析,
+// 出错的语句以ErrorNode代替, 所有语法错误以ErrorList返回
+func ParseWithRecovery(file string, reader io.Reader) ([]TreeNode, error) {
+	lexer := NewFileLexer(file, bufio.NewScanner(reader))
+	parser := NewSpawnParser()
+	var nodes []TreeNode
+	for {
+		t, err := lexer.Peek(0)
+		if err != nil {
+			lexer.addError(err.(*SyntaxError))
+			continue
+		}
+		if t == EOF {
+			break
+		}
+		node := parser.ParserWithRecovery(lexer)
+		if _, ok := node.(NullStatementNode); !ok {
+			nodes = append(nodes, node)
+		}
+	}
+	if errs := lexer.Errors(); len(errs) > 0 {
+		return nodes, ErrorList(errs)
+	}
+	return nodes, nil
+}
+
+// Eval 先以Resolve解析变量, 有错误时不执行任何语句并以ErrorList返回; 然后依次执行语句并返回最后一条语句的计算值,
+// 运行时错误以*RuntimeError、*TypeError、*NameError或*ArityError等返回, 其中记录了出错时的调用栈
+func Eval(nodes []TreeNode, env Environment) (interface{}, error) {
+	return EvalContext(context.Background(), nodes, env, Limits{})
+}
+
+// EvalContext 与Eval相同, 执行受limits限制, ctx取消时停止执行; 超出限制或被取消时以*LimitExceeded返回.
+// 执行结束时取消spawn创建且尚未结束的任务并等待其退出, 未被wait等待的任务出错时返回该任务的错误
+func EvalContext(ctx context.Context, nodes []TreeNode, env Environment, limits Limits) (interface{}, error) {
+	tasks := newTaskSet(ctx)
+	result, err := eval(ctx, nodes, env, limits, tasks)
+	// 结束时取消尚未结束的任务, 未被等待的任务出错时作为执行的错误返回
+	if taskErr := tasks.finish(); err == nil && taskErr != nil {
+		return nil, taskErr
+	}
+	return result, err
+}
+
+// eval 解析变量后在ctx中执行语句, spawn创建的任务属于tasks, 执行结束后不等待任务
+func eval(ctx context.Context, nodes []TreeNode, env Environment, limits Limits, tasks *taskSet) (result interface{}, err error) {
+	if err := Resolve(nodes, env); err != nil {
+		return nil, err
+	}
+	t := newThread(ctx, limits, tasks)
+	defer func() {
+		if r := recover(); r != nil {
+			t.unwind(r, 0)
+			result, err = nil, RecoveredError(r)
+		}
+	}()
+	top := &threadEnv{env, t}
+	for _, node := range nodes {
+		result = node.Eval(top)
+	}
+	return result, nil
+}
@@ -0,0 +1,266 @@
+package lexer
+
+import (
+	"math/big"
+	"simple-script-language/utils/list"
+	"strings"
+)
+
+// ArrayLiteralNode 数组字面量, 如[1, 2, 3]
+type ArrayLiteralNode struct {
+	BranchNode
+}
+
+// NewArrayLiteralNode 创建ArrayLiteralNode
+func NewArrayLiteralNode(list *list.ArrayList) ArrayLiteralNode {
+	return ArrayLiteralNode{NewBranchNode(list)}
+}
+
+// Size 元素个数
+func (a ArrayLiteralNode) Size() int {
+	return a.ChildSize()
+}
+
+// String 实现String接口
+func (a ArrayLiteralNode) String() string {
+	elements := make([]string, 0, a.ChildSize())
+	a.Children().For(func(k int, v interface{}) {
+		elements = append(elements, v.(TreeNode).String())
+	})
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// Eval 获取计算值, 数组以*list.ArrayList表示
+func (a ArrayLiteralNode) Eval(env Environment) interface{} {
+	allocate(env, a)
+	array := list.New(a.Size())
+	a.Children().For(func(k int, v interface{}) {
+		array.Add(v.(TreeNode).Eval(env))
+	})
+	return array
+}
+
+// ArrayRefNode 数组下标或切片后缀, 如a[i]、a[i:j]
+type ArrayRefNode struct {
+	BranchNode
+}
+
+// NewArrayRefNode 创建ArrayRefNode
+func NewArrayRefNode(list *list.ArrayList) ArrayRefNode {
+	return ArrayRefNode{NewBranchNode(list)}
+}
+
+// Index 获取下标表达式, 切片时为起始下标, 省略时返回nil
+func (a ArrayRefNode) Index() TreeNode {
+	if n, _ := a.Child(0); !isSlice(n) {
+		return n
+	}
+	return nil
+}
+
+// Slice 获取切片部分, 不是切片时返回false
+func (a ArrayRefNode) Slice() (SliceNode, bool) {
+	n, _ := a.Child(a.ChildSize() - 1)
+	s, ok := n.(SliceNode)
+	return s, ok
+}
+
+// String 实现String接口
+func (a ArrayRefNode) String() string {
+	var buf strings.Builder
+	buf.WriteString("[")
+	if index := a.Index(); index != nil {
+		buf.WriteString(index.String())
+	}
+	if s, ok := a.Slice(); ok {
+		buf.WriteString(s.String())
+	}
+	buf.WriteString("]")
+	return buf.String()
+}
+
+// EvalSub 以前面表达式的计算值获取元素、映射的值或切片
+func (a ArrayRefNode) EvalSub(env Environment, value interface{}) interface{} {
+	if a.ChildSize() > 2 || (a.ChildSize() == 2 && a.Index() == nil) {
+		panic(NewRuntimeError(a, "bad slice"))
+	}
+	if s, ok := a.Slice(); ok {
+		return a.GetSlice(value, evalOptional(env, a.Index()), evalOptional(env, s.End()))
+	}
+	return a.Get(value, a.Index().Eval(env))
+}
+
+// evalOptional 计算可省略的表达式, 省略时为nil
+func evalOptional(env Environment, node TreeNode) interface{} {
+	if node == nil {
+		return nil
+	}
+	return node.Eval(env)
+}
+
+// Get 获取value中下标为index的元素或映射中键为index的值
+func (a ArrayRefNode) Get(value interface{}, index interface{}) interface{} {
+	switch v := value.(type) {
+	case *Map:
+		key := mapKey(a.Index(), index)
+		if item, ok := v.Get(key); ok {
+			return item
+		}
+		panic(NewKeyError(a.Index(), key))
+	case *list.ArrayList:
+		item, _ := v.Get(a.index(index, v.Size()))
+		return item
+	case string:
+		runes := []rune(v)
+		return string(runes[a.index(index, len(runes))])
+	}
+	panic(NewTypeError(a, "bad array access"))
+}
+
+// GetSlice 获取value中[from, to)的切片, 下标为nil时表示省略, 超出范围的下标截取至边界
+func (a ArrayRefNode) GetSlice(value interface{}, from interface{}, to interface{}) interface{} {
+	switch v := value.(type) {
+	case *list.ArrayList:
+		start, end := a.bounds(from, to, v.Size())
+		result := list.New(end - start)
+		for i := start; i < end; i++ {
+			item, _ := v.Get(i)
+			result.Add(item)
+		}
+		return result
+	case string:
+		runes := []rune(v)
+		start, end := a.bounds(from, to, len(runes))
+		return string(runes[start:end])
+	}
+	panic(NewTypeError(a, "bad array access"))
+}
+
+// Assign 为数组元素或映射的键赋值
+func (a ArrayRefNode) Assign(env Environment, value interface{}, rightVal interface{}) interface{} {
+	if _, ok := a.Slice(); ok {
+		panic(NewRuntimeError(a, "bad assignment"))
+	}
+	return a.Set(value, a.Index().Eval(env), rightVal)
+}
+
+// Set 设置value中下标为index的元素或映射中键为index的值
+func (a ArrayRefNode) Set(value interface{}, index interface{}, rightVal interface{}) interface{} {
+	switch v := value.(type) {
+	case *list.ArrayList:
+		v.Set(a.index(index, v.Size()), rightVal)
+		return rightVal
+	case *Map:
+		v.Put(mapKey(a.Index(), index), rightVal)
+		return rightVal
+	}
+	panic(NewTypeError(a, "bad array access"))
+}
+
+// Delete 删除数组元素或映射的键, 返回被删除的值
+func (a ArrayRefNode) Delete(env Environment, value interface{}) interface{} {
+	if _, ok := a.Slice(); ok {
+		panic(NewRuntimeError(a, "bad del target"))
+	}
+	return a.Remove(value, a.Index().Eval(env))
+}
+
+// Remove 删除value中下标为index的元素或映射中键为index的值, 返回被删除的值
+func (a ArrayRefNode) Remove(value interface{}, index interface{}) interface{} {
+	switch v := value.(type) {
+	case *list.ArrayList:
+		item, _ := v.Remove(a.index(index, v.Size()))
+		return item
+	case *Map:
+		key := mapKey(a.Index(), index)
+		if item, ok := v.Delete(key); ok {
+			return item
+		}
+		panic(NewKeyError(a.Index(), key))
+	}
+	panic(NewTypeError(a, "bad array access"))
+}
+
+// index 检查下标并将负数下标转换为从结尾计算的下标
+func (a ArrayRefNode) index(value interface{}, size int) int {
+	node := a.Index()
+	i, ok := value.(int)
+	if !ok {
+		if _, large := value.(*big.Int); !large {
+			panic(NewTypeError(node, "bad index: %v", ToString(value)))
+		}
+		panic(NewIndexError(node, value, size))
+	}
+	if i < 0 {
+		i += size
+	}
+	if i < 0 || i >= size {
+		panic(NewIndexError(node, value, size))
+	}
+	return i
+}
+
+// bounds 获取切片的起止下标
+func (a ArrayRefNode) bounds(from interface{}, to interface{}, size int) (int, int) {
+	start, end := 0, size
+	if from != nil {
+		start = sliceIndex(a, from, size)
+	}
+	if to != nil {
+		end = sliceIndex(a, to, size)
+	}
+	if end < start {
+		end = start
+	}
+	return start, end
+}
+
+// sliceIndex 转换切片下标, 负数从结尾计算, 超出范围时截取至边界
+func sliceIndex(node TreeNode, value interface{}, size int) int {
+	var i int
+	switch v := value.(type) {
+	case int:
+		i = v
+	case *big.Int:
+		if v.Sign() < 0 {
+			return 0
+		}
+		return size
+	default:
+		panic(NewTypeError(node, "bad index: %v", ToString(value)))
+	}
+	return clampIndex(i, size)
+}
+
+// SliceNode 切片的":"及结束下标部分
+type SliceNode struct {
+	BranchNode
+}
+
+// NewSliceNode 创建SliceNode
+func NewSliceNode(list *list.ArrayList) SliceNode {
+	return SliceNode{NewBranchNode(list)}
+}
+
+// End 获取结束下标表达式, 省略时返回nil
+func (s SliceNode) End() TreeNode {
+	if s.ChildSize() > 0 {
+		n, _ := s.Child(0)
+		return n
+	}
+	return nil
+}
+
+// String 实现String接口
+func (s SliceNode) String() string {
+	if end := s.End(); end != nil {
+		return ":" + end.String()
+	}
+	return ":"
+}
+
+// isSlice 是否为切片部分
+func isSlice(node TreeNode) bool {
+	_, ok := node.(SliceNode)
+	return ok
+}
@@ -0,0 +1,476 @@
+package lexer
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"simple-script-language/utils/list"
+	"strconv"
+	"strings"
+)
+
+// NewBuiltinEnv 创建包含内置函数的环境, 输出函数写入out
+func NewBuiltinEnv(out io.Writer) BasicEnvironment {
+	env := NewBasicEnv()
+	InstallBuiltins(env, out)
+	return env
+}
+
+// InstallBuiltins 在env中注册内置函数, 输出函数写入out
+func InstallBuiltins(env Environment, out io.Writer) {
+	builtins := []*NativeFunction{
+		newBuiltin("print", 0, -1, func(args ...Value) (Value, error) {
+			_, err := io.WriteString(out, joinValues(args))
+			return nil, err
+		}),
+		newBuiltin("println", 0, -1, func(args ...Value) (Value, error) {
+			_, err := io.WriteString(out, joinValues(args)+"\n")
+			return nil, err
+		}),
+		newBuiltin("printf", 1, -1, func(args ...Value) (Value, error) {
+			format, err := stringArg(args, 0)
+			if err != nil {
+				return nil, err
+			}
+			_, err = fmt.Fprintf(out, format, formatArgs(args[1:])...)
+			return nil, err
+		}),
+		newBuiltin("len", 1, 1, builtinLen),
+		newThreaded("str", 1, 1, func(t *thread, node TreeNode, args []Value) (Value, error) {
+			s := ToString(args[0])
+			t.allocString(node, len(s))
+			return s, nil
+		}),
+		newBuiltin("int", 1, 1, builtinInt),
+		newBuiltin("float", 1, 1, builtinFloat),
+		newBuiltin("type", 1, 1, func(args ...Value) (Value, error) {
+			return TypeName(args[0]), nil
+		}),
+		newBuiltin("abs", 1, 1, builtinAbs),
+		newBuiltin("min", 1, -1, func(args ...Value) (Value, error) {
+			return extreme(args, -1)
+		}),
+		newBuiltin("max", 1, -1, func(args ...Value) (Value, error) {
+			return extreme(args, 1)
+		}),
+		newBuiltin("pow", 2, 2, builtinPow),
+		newBuiltin("sqrt", 1, 1, func(args ...Value) (Value, error) {
+			x, err := numberArg(args, 0)
+			if err != nil {
+				return nil, err
+			}
+			return math.Sqrt(toFloat(x)), nil
+		}),
+		newThreaded("split", 2, 2, builtinSplit),
+		newThreaded("join", 2, 2, builtinJoin),
+		stringBuiltin("upper", strings.ToUpper),
+		stringBuiltin("lower", strings.ToLower),
+		stringBuiltin("trim", strings.TrimSpace),
+		newThreaded("replace", 3, 3, builtinReplace),
+		newBuiltin("substr", 2, 3, builtinSubstr),
+		newBuiltin("contains", 2, 2, func(args ...Value) (Value, error) {
+			s, sub, err := twoStrings(args)
+			if err != nil {
+				return nil, err
+			}
+			return strings.Contains(s, sub), nil
+		}),
+		newThreaded("range", 1, 3, builtinRange),
+		newBuiltin("error", 1, 2, builtinError),
+		newBuiltin("channel", 0, 1, builtinChannel),
+		newThreaded("select", 0, -1, builtinSelect),
+		newThreaded("wait", 1, -1, builtinWait),
+	}
+	for _, f := range builtins {
+		env.PutNew(f.name, f)
+	}
+}
+
+// TypeName 获取值的类型名
+func TypeName(v Value) string {
+	switch v.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "bool"
+	case int, *big.Int:
+		return "int"
+	case float64:
+		return "float"
+	case string:
+		return "string"
+	case *list.ArrayList:
+		return "array"
+	case *Map:
+		return "map"
+	case *Function, *NativeFunction:
+		return "function"
+	case *ClassInfo:
+		return "class"
+	case *Object, *Super:
+		return "object"
+	case *GoObject:
+		return "go"
+	case interface{ TypeName() string }:
+		return v.(interface{ TypeName() string }).TypeName()
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// joinValues 以空格连接各个值的字符串形式
+func joinValues(args []Value) string {
+	parts := make([]string, len(args))
+	for i, v := range args {
+		parts[i] = ToString(v)
+	}
+	return strings.Join(parts, " ")
+}
+
+// formatArgs 转换printf的参数, nil、浮点数、数组和映射以字符串形式输出
+func formatArgs(args []Value) []interface{} {
+	result := make([]interface{}, len(args))
+	for i, v := range args {
+		switch v.(type) {
+		case nil, *list.ArrayList, *Map:
+			result[i] = ToString(v)
+		default:
+			result[i] = v
+		}
+	}
+	return result
+}
+
+// badArgument 参数类型错误
+func badArgument(args []Value, i int, expected string) error {
+	return &argumentError{i + 1, conversionErrorf("cannot use %v as %v", toString(args[i], true, nil), expected)}
+}
+
+// stringArg 获取字符串参数
+func stringArg(args []Value, i int) (string, error) {
+	s, ok := args[i].(string)
+	if !ok {
+		return "", badArgument(args, i, "string")
+	}
+	return s, nil
+}
+
+// twoStrings 获取前两个字符串参数
+func twoStrings(args []Value) (string, string, error) {
+	a, err := stringArg(args, 0)
+	if err != nil {
+		return "", "", err
+	}
+	b, err := stringArg(args, 1)
+	return a, b, err
+}
+
+// intArg 获取整数参数
+func intArg(args []Value, i int) (int, error) {
+	n, ok := args[i].(int)
+	if !ok {
+		return 0, badArgument(args, i, "int")
+	}
+	return n, nil
+}
+
+// numberArg 获取数值参数
+func numberArg(args []Value, i int) (Value, error) {
+	if !isNumber(args[i]) {
+		return nil, badArgument(args, i, "number")
+	}
+	return args[i], nil
+}
+
+// arrayArg 获取数组参数
+func arrayArg(args []Value, i int) (*list.ArrayList, error) {
+	a, ok := args[i].(*list.ArrayList)
+	if !ok {
+		return nil, badArgument(args, i, "array")
+	}
+	return a, nil
+}
+
+// stringBuiltin 以字符串转换函数创建内置函数, 按结果的长度计数分配
+func stringBuiltin(name string, fn func(string) string) *NativeFunction {
+	return newThreaded(name, 1, 1, func(t *thread, node TreeNode, args []Value) (Value, error) {
+		s, err := stringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		result := fn(s)
+		t.allocString(node, len(result))
+		return result, nil
+	})
+}
+
+// builtinSplit 以sep分割字符串split(s, sep), 先按结果的元素个数计数分配
+func builtinSplit(t *thread, node TreeNode, args []Value) (Value, error) {
+	s, sep, err := twoStrings(args)
+	if err != nil {
+		return nil, err
+	}
+	t.alloc(node, strings.Count(s, sep)+1)
+	result := list.New(0)
+	for i, part := range strings.Split(s, sep) {
+		if i%checkInterval == 0 {
+			t.check(node)
+		}
+		result.Add(part)
+	}
+	return result, nil
+}
+
+// builtinJoin 以sep连接数组中各个值的字符串形式join(array, sep), 先按结果的长度计数分配
+func builtinJoin(t *thread, node TreeNode, args []Value) (Value, error) {
+	array, err := arrayArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	sep, err := stringArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	parts := make([]string, 0, array.Size())
+	size := 0
+	array.For(func(k int, v interface{}) {
+		if k%checkInterval == 0 {
+			t.check(node)
+		}
+		part := ToString(v)
+		parts = append(parts, part)
+		size += len(part) + len(sep)
+	})
+	t.allocString(node, size)
+	return strings.Join(parts, sep), nil
+}
+
+// builtinReplace 替换全部子串replace(s, old, new), 先按结果的长度计数分配
+func builtinReplace(t *thread, node TreeNode, args []Value) (Value, error) {
+	s, old, err := twoStrings(args)
+	if err != nil {
+		return nil, err
+	}
+	replacement, err := stringArg(args, 2)
+	if err != nil {
+		return nil, err
+	}
+	t.allocString(node, len(s)+strings.Count(s, old)*len(replacement))
+	return strings.Replace(s, old, replacement, -1), nil
+}
+
+// builtinLen 字符串的字符数、数组的元素个数或映射的键值对个数
+func builtinLen(args ...Value) (Value, error) {
+	switch v := args[0].(type) {
+	case string:
+		return len([]rune(v)), nil
+	case *list.ArrayList:
+		return v.Size(), nil
+	case *Map:
+		return v.Size(), nil
+	}
+	return nil, badArgument(args, 0, "string, array or map")
+}
+
+// builtinInt 转换为整数, 浮点数向零取整, 字符串按整数字面量解析
+func builtinInt(args ...Value) (Value, error) {
+	switch v := args[0].(type) {
+	case int, *big.Int:
+		return v, nil
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, fmt.Errorf("cannot convert %v to int", formatFloat(v))
+		}
+		n, _ := big.NewFloat(math.Trunc(v)).Int(nil)
+		return normalize(n), nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		n, ok := parseNumber(strings.TrimSpace(v))
+		if _, isFloat := n.(float64); !ok || isFloat {
+			return nil, fmt.Errorf("invalid int literal: %q", v)
+		}
+		return n, nil
+	}
+	return nil, badArgument(args, 0, "number, bool or string")
+}
+
+// builtinFloat 转换为浮点数
+func builtinFloat(args ...Value) (Value, error) {
+	switch v := args[0].(type) {
+	case int, float64, *big.Int:
+		return toFloat(v), nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid float literal: %q", v)
+		}
+		return f, nil
+	}
+	return nil, badArgument(args, 0, "number or string")
+}
+
+// builtinAbs 绝对值
+func builtinAbs(args ...Value) (Value, error) {
+	x, err := numberArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	if compareNumbers(x, 0) < 0 {
+		x, _ = negate(x)
+	}
+	return x, nil
+}
+
+// extreme 最小值(sign为-1)或最大值(sign为1), 只有一个数组参数时比较数组中的元素
+func extreme(args []Value, sign int) (Value, error) {
+	values := args
+	if a, ok := args[0].(*list.ArrayList); ok && len(args) == 1 {
+		if a.Size() == 0 {
+			return nil, fmt.Errorf("empty array")
+		}
+		values = make([]Value, 0, a.Size())
+		a.For(func(k int, v interface{}) {
+			values = append(values, v)
+		})
+	}
+	var result Value
+	for i, v := range values {
+		if !isNumber(v) {
+			return nil, badArgument(values, i, "number")
+		}
+		if result == nil || compareNumbers(v, result)*sign > 0 {
+			result = v
+		}
+	}
+	return result, nil
+}
+
+// builtinPow 幂运算, 整数的非负整数次幂结果为整数
+func builtinPow(args ...Value) (Value, error) {
+	x, err := numberArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	y, err := numberArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	_, xFloat := x.(float64)
+	if y, ok := y.(int); ok && !xFloat && y >= 0 {
+		return normalize(new(big.Int).Exp(toBig(x), big.NewInt(int64(y)), nil)), nil
+	}
+	return math.Pow(toFloat(x), toFloat(y)), nil
+}
+
+// builtinSubstr 按字符截取子串substr(s, start[, length]), start为负数时从结尾计算
+func builtinSubstr(args ...Value) (Value, error) {
+	s, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	runes := []rune(s)
+	start, err := intArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	start = clampIndex(start, len(runes))
+	end := len(runes)
+	if len(args) > 2 {
+		length, err := intArg(args, 2)
+		if err != nil {
+			return nil, err
+		}
+		if length < 0 {
+			return nil, fmt.Errorf("negative length: %v", length)
+		}
+		if length < end-start {
+			end = start + length
+		}
+	}
+	return string(runes[start:end]), nil
+}
+
+// clampIndex 负数下标从结尾计算, 超出范围时截取至边界
+func clampIndex(i int, size int) int {
+	if i < 0 {
+		i += size
+	}
+	if i < 0 {
+		return 0
+	}
+	if i > size {
+		return size
+	}
+	return i
+}
+
+// builtinRange 整数序列range(stop)、range(start, stop)或range(start, stop, step), 先按元素个数计数分配
+func builtinRange(t *thread, node TreeNode, args []Value) (Value, error) {
+	bounds := make([]int, len(args))
+	for i := range args {
+		n, err := intArg(args, i)
+		if err != nil {
+			return nil, err
+		}
+		bounds[i] = n
+	}
+	start, stop, step := 0, bounds[0], 1
+	if len(bounds) > 1 {
+		start, stop = bounds[0], bounds[1]
+	}
+	if len(bounds) > 2 {
+		step = bounds[2]
+	}
+	if step == 0 {
+		return nil, fmt.Errorf("step must not be zero")
+	}
+	t.alloc(node, rangeSize(start, stop, step))
+	result := list.New(0)
+	for i := start; (step > 0 && i < stop) || (step < 0 && i > stop); i += step {
+		if result.Size()%checkInterval == 0 {
+			t.check(node)
+		}
+		result.Add(i)
+		if (step > 0 && i > maxInt-step) || (step < 0 && i < minInt-step) {
+			break
+		}
+	}
+	return result, nil
+}
+
+// rangeSize range(start, stop, step)的元素个数, 超出int的范围时返回int的最大值
+func rangeSize(start, stop, step int) int {
+	var distance, stride uint64
+	switch {
+	case step > 0 && start < stop:
+		distance, stride = uint64(stop)-uint64(start), uint64(step)
+	case step < 0 && start > stop:
+		distance, stride = uint64(start)-uint64(stop), -uint64(step)
+	default:
+		return 0
+	}
+	n := (distance-1)/stride + 1
+	if n > uint64(maxInt) {
+		return maxInt
+	}
+	return int(n)
+}
+
+// builtinError 以错误信息及可选的错误类型名称创建错误值, 错误类型默认为Error
+func builtinError(args ...Value) (Value, error) {
+	message, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	kind := "Error"
+	if len(args) > 1 {
+		if kind, err = stringArg(args, 1); err != nil {
+			return nil, err
+		}
+	}
+	return NewErrorValue(kind, message), nil
+}
@@ -0,0 +1,230 @@
+package lexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// StackFrame 调用栈中的一层
+type StackFrame struct {
+	Function string   // 函数名, 顶层代码为<module>, 匿名函数为<fun>
+	Position Position // 该层正在执行的位置, 即调用下一层的位置或出错位置
+}
+
+// Traceback 运行时错误发生时的调用栈, 最近的调用在最后
+type Traceback []StackFrame
+
+// String 实现String接口, 格式与Python的调用栈相同, 连续重复超过3次的行只输出重复次数
+func (t Traceback) String() string {
+	var buf strings.Builder
+	buf.WriteString("Traceback (most recent call last):")
+	last, repeated := "", 0
+	flush := func() {
+		if repeated > 3 {
+			fmt.Fprintf(&buf, "\n  [Previous line repeated %v more times]", repeated-3)
+		}
+	}
+	for _, f := range t {
+		file := f.Position.File
+		if file == "" {
+			file = "<input>"
+		}
+		line := fmt.Sprintf("\n  File %q, line %v, in %v", file, f.Position.Line, f.Function)
+		if line == last {
+			repeated++
+			if repeated <= 3 {
+				buf.WriteString(line)
+			}
+			continue
+		}
+		flush()
+		buf.WriteString(line)
+		last, repeated = line, 1
+	}
+	flush()
+	return buf.String()
+}
+
+// call 调用栈中的一次函数调用
+type call struct {
+	function *Function
+	site     TreeNode // 调用表达式
+}
+
+// Limits 执行限制, 为0的项不限制; 调用深度总是受限, 以免递归耗尽宿主goroutine的栈
+type Limits struct {
+	MaxSteps       int // 最多执行的步数, 循环的每次迭代及每次函数调用各为一步
+	MaxCallDepth   int // 脚本函数的最大调用深度, 为0时为DefaultCallDepth, 最大为CallDepthLimit
+	MaxAllocations int // 最多分配的单位数, 每个数组、映射、函数及对象计1, 内置函数创建的数组每个元素计1, 字符串每StringUnit字节计1
+}
+
+const (
+	// DefaultCallDepth MaxCallDepth为0时的最大调用深度
+	DefaultCallDepth = 10000
+	// CallDepthLimit 最大调用深度的上限, 更深的递归会耗尽宿主goroutine的栈
+	CallDepthLimit = 50000
+	// StringUnit 字符串每StringUnit字节计为一个分配单位
+	StringUnit = 1024
+	// checkInterval 内置函数创建较大的值时每处理checkInterval个元素检查一次是否已取消
+	checkInterval = 1024
+)
+
+// CallDepth 实际的最大调用深度
+func (l Limits) CallDepth() int {
+	switch {
+	case l.MaxCallDepth <= 0:
+		return DefaultCallDepth
+	case l.MaxCallDepth > CallDepthLimit:
+		return CallDepthLimit
+	}
+	return l.MaxCallDepth
+}
+
+// group 一次执行中所有线程共享的状态, 记录执行限制的计数及spawn创建的任务所属的集合
+type group struct {
+	steps  int64 // 以原子操作访问
+	allocs int64 // 以原子操作访问
+	limits Limits
+	tasks  *taskSet
+}
+
+// thread 一个goroutine中的执行状态, 记录脚本函数的调用栈, 执行限制由同一次执行中的所有线程共同计数
+type thread struct {
+	*group
+	ctx   context.Context
+	calls []call
+	done  <-chan struct{} // ctx取消时关闭
+	root  string          // 调用栈最外层的函数名, 顶层代码为<module>, 任务为创建任务处所在的函数
+}
+
+// newThread 创建受limits限制、ctx取消时停止的执行状态, spawn创建的任务属于tasks
+func newThread(ctx context.Context, limits Limits, tasks *taskSet) *thread {
+	return &thread{group: &group{limits: limits, tasks: tasks}, ctx: ctx, done: ctx.Done(), root: "<module>"}
+}
+
+// step 执行一步, 超出步数限制或已取消时在node处抛出LimitExceeded
+func (t *thread) step(node TreeNode) {
+	if max := t.limits.MaxSteps; max > 0 && atomic.AddInt64(&t.steps, 1) > int64(max) {
+		panic(NewLimitExceeded(node, "step", max))
+	}
+	t.check(node)
+}
+
+// check 已取消时在node处抛出LimitExceeded
+func (t *thread) check(node TreeNode) {
+	select {
+	case <-t.done:
+		panic(newCanceledError(node, t.ctx.Err()))
+	default:
+	}
+}
+
+// alloc 分配n个单位, 超出限制时在node处抛出LimitExceeded
+func (t *thread) alloc(node TreeNode, n int) {
+	if max := t.limits.MaxAllocations; max > 0 && n > 0 && atomic.AddInt64(&t.allocs, int64(n)) > int64(max) {
+		panic(NewLimitExceeded(node, "allocation", max))
+	}
+}
+
+// allocString 按字节数计数创建的长度为size的字符串
+func (t *thread) allocString(node TreeNode, size int) {
+	t.alloc(node, size/StringUnit)
+}
+
+// enter 进入函数调用, 计为一步并检查调用深度; 调用深度按线程分别计算
+func (t *thread) enter(f *Function, site TreeNode) {
+	t.step(site)
+	if max := t.limits.CallDepth(); len(t.calls) >= max {
+		panic(NewLimitExceeded(site, "call depth", max))
+	}
+	t.calls = append(t.calls, call{f, site})
+}
+
+// leave 离开函数调用; 出错时不会执行, 由捕获错误处的unwind恢复调用栈
+func (t *thread) leave() {
+	t.calls = t.calls[:len(t.calls)-1]
+}
+
+// function 当前正在执行的函数名
+func (t *thread) function() string {
+	if len(t.calls) == 0 {
+		return t.root
+	}
+	return t.calls[len(t.calls)-1].function.Name()
+}
+
+// traceback 获取当前的调用栈, 最内层的位置为pos
+func (t *thread) traceback(pos Position) Traceback {
+	frames := make(Traceback, len(t.calls)+1)
+	frames[0].Function = t.root
+	for i, c := range t.calls {
+		frames[i].Position = c.site.Span().Start
+		frames[i+1].Function = c.function.Name()
+	}
+	frames[len(t.calls)].Position = pos
+	return frames
+}
+
+// unwind 捕获到r后调用, 为尚未记录调用栈的运行时错误记录调用栈, 然后将调用栈恢复至depth层
+func (t *thread) unwind(r interface{}, depth int) {
+	if err, ok := r.(error); ok {
+		var re *RuntimeError
+		if errors.As(err, &re) && re.Traceback == nil {
+			re.Traceback = t.traceback(re.Position)
+		}
+	}
+	t.calls = t.calls[:depth]
+}
+
+// threadEnv 为顶层代码或类体记录当前执行状态的环境, 其他操作均交给原来的环境
+type threadEnv struct {
+	Environment
+	thread *thread
+}
+
+// threadOf 获取在env中执行的代码所属的执行状态, 没有时返回nil
+func threadOf(env Environment) *thread {
+	for {
+		switch e := env.(type) {
+		case *Frame:
+			if e.thread != nil {
+				return e.thread
+			}
+			env = e.outer
+		case *threadEnv:
+			return e.thread
+		case *NestedEnvironment:
+			env = e.Outer()
+		default:
+			return nil
+		}
+	}
+}
+
+// currentThread 获取在env中执行的代码所属的执行状态, 没有时创建不受限制的执行状态
+func currentThread(env Environment) *thread {
+	if t := threadOf(env); t != nil {
+		return t
+	}
+	return newThread(context.Background(), Limits{}, newTaskSet(context.Background()))
+}
+
+// allocate 在env所属的执行状态中计数创建的数组、映射、函数或对象
+func allocate(env Environment, node TreeNode) {
+	if t := threadOf(env); t != nil {
+		t.alloc(node, 1)
+	}
+}
+
+// allocateString 在env所属的执行状态中按字节数计数创建的字符串, 不足StringUnit字节时不计数
+func allocateString(env Environment, node TreeNode, size int) {
+	if size < StringUnit {
+		return
+	}
+	if t := threadOf(env); t != nil {
+		t.allocString(node, size)
+	}
+}
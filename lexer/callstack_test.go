@@ -0,0 +1,65 @@
+package lexer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimits(t *testing.T) {
+	doubling := "s = \"ab\"\ni = 0\nwhile i < 40 { s = s + s\ni = i + 1 }"
+	tests := []struct {
+		src    string
+		limits Limits
+		limit  string
+	}{
+		{"x = range(200000000)", Limits{MaxAllocations: 10}, "allocation"},
+		{`x = split(str(range(2000)), ",")`, Limits{MaxAllocations: 2000 + 20}, "allocation"},
+		{`x = join(range(1000), "0123456789")`, Limits{MaxAllocations: 1000 + 10}, "allocation"},
+		{"s = \"" + strings.Repeat("a", 32) + "\"\nx = replace(replace(s, \"a\", s), \"a\", s)", Limits{MaxAllocations: 10}, "allocation"},
+		{doubling, Limits{MaxAllocations: 10, MaxSteps: 100}, "allocation"},
+		{"def f(n) { f(n + 1) }\nf(0)", Limits{}, "call depth"},
+		{"def f(n) { f(n + 1) }\nf(0)", Limits{MaxCallDepth: 1 << 30}, "call depth"},
+		{"while true {}", Limits{MaxSteps: 100}, "step"},
+	}
+	for _, tt := range tests {
+		nodes, err := Parse("test.ssl", strings.NewReader(tt.src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = EvalContext(context.Background(), nodes, NewNestedEnvironment(NewBuiltinEnv(nil)), tt.limits)
+		var le *LimitExceeded
+		if !errors.As(err, &le) || le.Limit != tt.limit {
+			t.Errorf("%q: got %v, want %v limit exceeded", tt.src, err, tt.limit)
+		}
+	}
+}
+
+func TestCallDepth(t *testing.T) {
+	tests := []struct {
+		max, want int
+	}{
+		{0, DefaultCallDepth},
+		{-1, DefaultCallDepth},
+		{100, 100},
+		{CallDepthLimit + 1, CallDepthLimit},
+	}
+	for _, tt := range tests {
+		if got := (Limits{MaxCallDepth: tt.max}).CallDepth(); got != tt.want {
+			t.Errorf("CallDepth(%v) = %v, want %v", tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestBuiltinCanceled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var out bytes.Buffer
+	_, err := NewInterpreter(Config{Out: &out}).Exec(ctx, "test.ssl", strings.NewReader("x = range(2000000000)"))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want deadline exceeded", err)
+	}
+}
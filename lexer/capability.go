@@ -0,0 +1,177 @@
+package lexer
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"simple-script-language/utils/list"
+	"strings"
+)
+
+// Capability 宿主授予脚本的能力, 在解释器的内置函数环境中注册对应的函数
+type Capability interface {
+	Install(env Environment)
+}
+
+// fileSystem 访问root目录中文件的能力
+type fileSystem struct {
+	root     string
+	writable bool
+}
+
+// FileSystem 授予访问root目录中文件的能力, 注册readfile(path)、listdir(path)、exists(path),
+// writable为true时还注册writefile(path, text); 脚本中的路径均相对root, 不能以".."或符号链接访问root之外的文件,
+// 错误信息中只包含脚本中的路径. 一个环境中只能授予一个FileSystem, 后授予的代替先授予的
+func FileSystem(root string, writable bool) Capability {
+	return fileSystem{root, writable}
+}
+
+// errOutside 路径解析符号链接后不在root中
+var errOutside = errors.New("path outside the granted directory")
+
+// path 脚本中的路径name对应的文件路径, 解析符号链接后不在root中时返回错误; 错误以op及name描述, 不包含宿主中的路径
+func (f fileSystem) path(op string, name string) (string, error) {
+	root, err := filepath.EvalSymlinks(f.root)
+	if err == nil {
+		root, err = filepath.Abs(root)
+	}
+	if err != nil {
+		return "", pathError(op, name, err)
+	}
+	file := filepath.Join(root, filepath.FromSlash(path.Clean("/"+name)))
+	resolved, err := filepath.EvalSymlinks(file)
+	if os.IsNotExist(err) {
+		// 文件不存在时解析所在的目录, 以便创建文件; 指向不存在的文件的符号链接不能写入
+		if _, err := os.Lstat(file); err == nil {
+			return "", pathError(op, name, errOutside)
+		}
+		var dir string
+		if dir, err = filepath.EvalSymlinks(filepath.Dir(file)); err != nil {
+			return "", pathError(op, name, err)
+		}
+		resolved = filepath.Join(dir, filepath.Base(file))
+	} else if err != nil {
+		return "", pathError(op, name, err)
+	}
+	if rel, err := filepath.Rel(root, resolved); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", pathError(op, name, errOutside)
+	}
+	return resolved, nil
+}
+
+// pathError 以脚本中的路径name代替宿主中的路径描述错误
+func pathError(op string, name string, err error) error {
+	var pe *os.PathError
+	if errors.As(err, &pe) {
+		err = pe.Err
+	}
+	return &os.PathError{Op: op, Path: name, Err: err}
+}
+
+// Install 注册文件访问函数
+func (f fileSystem) Install(env Environment) {
+	builtins := []*NativeFunction{
+		newBuiltin("readfile", 1, 1, func(args ...Value) (Value, error) {
+			name, err := stringArg(args, 0)
+			if err != nil {
+				return nil, err
+			}
+			file, err := f.path("open", name)
+			if err != nil {
+				return nil, err
+			}
+			data, err := ioutil.ReadFile(file)
+			if err != nil {
+				return nil, pathError("open", name, err)
+			}
+			return string(data), nil
+		}),
+		newBuiltin("listdir", 1, 1, func(args ...Value) (Value, error) {
+			name, err := stringArg(args, 0)
+			if err != nil {
+				return nil, err
+			}
+			dir, err := f.path("open", name)
+			if err != nil {
+				return nil, err
+			}
+			infos, err := ioutil.ReadDir(dir)
+			if err != nil {
+				return nil, pathError("open", name, err)
+			}
+			result := list.New(len(infos))
+			for _, info := range infos {
+				result.Add(info.Name())
+			}
+			return result, nil
+		}),
+		newBuiltin("exists", 1, 1, func(args ...Value) (Value, error) {
+			name, err := stringArg(args, 0)
+			if err != nil {
+				return nil, err
+			}
+			file, err := f.path("stat", name)
+			if err != nil {
+				return false, nil
+			}
+			_, err = os.Stat(file)
+			return err == nil, nil
+		}),
+	}
+	if f.writable {
+		builtins = append(builtins, newBuiltin("writefile", 2, 2, func(args ...Value) (Value, error) {
+			name, text, err := twoStrings(args)
+			if err != nil {
+				return nil, err
+			}
+			file, err := f.path("open", name)
+			if err != nil {
+				return nil, err
+			}
+			if err := ioutil.WriteFile(file, []byte(text), 0644); err != nil {
+				return nil, pathError("open", name, err)
+			}
+			return nil, nil
+		}))
+	}
+	for _, fn := range builtins {
+		env.PutNew(fn.name, fn)
+	}
+}
+
+// environ 读取环境变量的能力
+type environ struct {
+	names map[string]bool
+}
+
+// Environ 授予读取环境变量的能力, 注册getenv(name), 变量不存在或不允许读取时返回nil;
+// names不为空时只能读取其中的变量
+func Environ(names ...string) Capability {
+	e := environ{}
+	if len(names) > 0 {
+		e.names = make(map[string]bool)
+		for _, name := range names {
+			e.names[name] = true
+		}
+	}
+	return e
+}
+
+// Install 注册环境变量访问函数
+func (e environ) Install(env Environment) {
+	env.PutNew("getenv", newBuiltin("getenv", 1, 1, func(args ...Value) (Value, error) {
+		name, err := stringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		if e.names != nil && !e.names[name] {
+			return nil, nil
+		}
+		if v, ok := os.LookupEnv(name); ok {
+			return v, nil
+		}
+		return nil, nil
+	}))
+}
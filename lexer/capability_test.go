@@ -0,0 +1,61 @@
+package lexer
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileSystem(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	secret := filepath.Join(dir, "secret.txt")
+	for _, err := range []error{
+		os.Mkdir(root, 0755),
+		ioutil.WriteFile(filepath.Join(root, "f.txt"), []byte("hi"), 0644),
+		ioutil.WriteFile(secret, []byte("secret"), 0644),
+		os.Symlink(secret, filepath.Join(root, "link")),
+		os.Symlink(dir, filepath.Join(root, "dir")),
+		os.Symlink(filepath.Join(dir, "missing.txt"), filepath.Join(root, "dangling")),
+		os.Symlink(filepath.Join(root, "f.txt"), filepath.Join(root, "inner")),
+	} {
+		if err != nil {
+			t.Skip(err)
+		}
+	}
+	tests := []struct {
+		src  string
+		want string // 为错误信息时应当出错
+	}{
+		{`readfile("f.txt")`, "hi"},
+		{`readfile("inner")`, "hi"},
+		{`readfile("../secret.txt")`, "open ../secret.txt: no such file or directory"},
+		{`readfile("link")`, "open link: path outside the granted directory"},
+		{`readfile("dir/secret.txt")`, "open dir/secret.txt: path outside the granted directory"},
+		{`listdir("dir")`, "open dir: path outside the granted directory"},
+		{`exists("link")`, "false"},
+		{`writefile("dangling", "x")`, "open dangling: path outside the granted directory"},
+		{`writefile("dir/new.txt", "x")`, "open dir/new.txt: path outside the granted directory"},
+		{`writefile("new.txt", "x")` + "\nreadfile(\"new.txt\")", "x"},
+	}
+	for _, tt := range tests {
+		interp := NewInterpreter(Config{Grants: []Capability{FileSystem(root, true)}})
+		v, err := interp.Exec(context.Background(), "test.ssl", strings.NewReader(tt.src))
+		got := ToString(v)
+		if err != nil {
+			got = err.Error()
+			if strings.Contains(got, dir) {
+				t.Errorf("%q: error shows the host path: %v", tt.src, err)
+			}
+		}
+		if !strings.Contains(got, tt.want) {
+			t.Errorf("%q: got %q, want %q", tt.src, got, tt.want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "missing.txt")); !os.IsNotExist(err) {
+		t.Errorf("wrote through a dangling link: %v", err)
+	}
+}
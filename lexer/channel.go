@@ -0,0 +1,166 @@
+package lexer
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"simple-script-language/utils/list"
+)
+
+// Channel 通道, 由内置函数channel创建, 用于在任务之间传递值;
+// 成员send(v)发送值, recv()接收值(通道关闭后返回nil), close()关闭通道
+type Channel struct {
+	ch chan interface{}
+}
+
+// NewChannel 创建缓冲区大小为size的通道
+func NewChannel(size int) *Channel {
+	return &Channel{make(chan interface{}, size)}
+}
+
+// String 实现String接口
+func (c *Channel) String() string {
+	return fmt.Sprintf("<channel:%p>", c)
+}
+
+// TypeName 类型名
+func (c *Channel) TypeName() string {
+	return "channel"
+}
+
+// Read 读取成员send、recv、close、len、cap
+func (c *Channel) Read(member string) (interface{}, bool) {
+	switch member {
+	case "send":
+		return newThreaded("send", 1, 1, func(t *thread, node TreeNode, args []Value) (Value, error) {
+			return nil, c.send(t, node, args[0])
+		}), true
+	case "recv":
+		return newThreaded("recv", 0, 0, func(t *thread, node TreeNode, args []Value) (Value, error) {
+			v, _, err := c.recv(t, node)
+			return v, err
+		}), true
+	case "close":
+		return newBuiltin("close", 0, 0, func(args ...Value) (Value, error) {
+			return nil, c.close()
+		}), true
+	case "len":
+		return len(c.ch), true
+	case "cap":
+		return cap(c.ch), true
+	}
+	return nil, false
+}
+
+// send 发送值, 值在发送前以escape标记; 阻塞时执行状态t被取消则在node处返回LimitExceeded
+func (c *Channel) send(t *thread, node TreeNode, v Value) (err error) {
+	escape(v)
+	defer recoverChannel(&err)
+	select {
+	case c.ch <- v:
+		return nil
+	case <-t.done:
+		return newCanceledError(node, t.ctx.Err())
+	}
+}
+
+// recv 接收值, 通道已关闭且没有值时ok为false
+func (c *Channel) recv(t *thread, node TreeNode) (v Value, ok bool, err error) {
+	select {
+	case v, ok = <-c.ch:
+		return v, ok, nil
+	case <-t.done:
+		return nil, false, newCanceledError(node, t.ctx.Err())
+	}
+}
+
+// close 关闭通道
+func (c *Channel) close() (err error) {
+	defer recoverChannel(&err)
+	close(c.ch)
+	return nil
+}
+
+// recoverChannel 将操作已关闭的通道引起的panic转换为错误
+func recoverChannel(err *error) {
+	if r := recover(); r != nil {
+		*err = errors.New(fmt.Sprint(r))
+	}
+}
+
+// channelIterator 通道的迭代器, 依次接收值直到通道关闭
+type channelIterator struct {
+	channel *Channel
+	thread  *thread
+	node    TreeNode
+}
+
+// Next 下一个值, 执行被取消时抛出LimitExceeded
+func (c *channelIterator) Next() (Value, bool) {
+	v, ok, err := c.channel.recv(c.thread, c.node)
+	if err != nil {
+		panic(err)
+	}
+	return v, ok
+}
+
+// builtinChannel 创建通道channel([size])
+func builtinChannel(args ...Value) (Value, error) {
+	size := 0
+	if len(args) > 0 {
+		n, err := intArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, fmt.Errorf("negative size: %v", n)
+		}
+		size = n
+	}
+	return NewChannel(size), nil
+}
+
+// builtinSelect 同时等待多个通道操作select(case, ...), 通道表示接收, [通道, 值]表示发送,
+// 最后一个参数为nil时没有可执行的操作则立即返回; 返回[执行的参数下标, 接收的值, 是否接收到值]
+func builtinSelect(t *thread, node TreeNode, args []Value) (result Value, err error) {
+	cases := make([]reflect.SelectCase, 0, len(args)+1)
+	for i, v := range args {
+		switch c := v.(type) {
+		case *Channel:
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(c.ch)})
+			continue
+		case *list.ArrayList:
+			if c.Size() == 2 {
+				item, _ := c.Get(0)
+				value, _ := c.Get(1)
+				if ch, ok := item.(*Channel); ok {
+					escape(value)
+					send := reflect.ValueOf(&value).Elem()
+					cases = append(cases, reflect.SelectCase{Dir: reflect.SelectSend, Chan: reflect.ValueOf(ch.ch), Send: send})
+					continue
+				}
+			}
+		case nil:
+			if i == len(args)-1 {
+				cases = append(cases, reflect.SelectCase{Dir: reflect.SelectDefault})
+				continue
+			}
+		}
+		return nil, badArgument(args, i, "channel, [channel, value] or trailing nil")
+	}
+	cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(t.done)})
+	defer recoverChannel(&err)
+	chosen, recv, ok := reflect.Select(cases)
+	if chosen == len(cases)-1 {
+		return nil, newCanceledError(node, t.ctx.Err())
+	}
+	var value Value
+	if ok {
+		value = recv.Interface()
+	}
+	selected := list.New(3)
+	selected.Add(chosen)
+	selected.Add(value)
+	selected.Add(ok)
+	return selected, nil
+}
@@ -0,0 +1,212 @@
+package lexer
+
+import (
+	"fmt"
+	"simple-script-language/utils/list"
+)
+
+// ClassInfo 类定义对象
+type ClassInfo struct {
+	definition ClassStatementNode // 类定义
+	env        Environment        // 定义类时的环境
+	superClass *ClassInfo         // 父类, 没有时为nil
+}
+
+// NewClassInfo 创建ClassInfo, 类引用的帧此后加锁访问
+func NewClassInfo(definition ClassStatementNode, env Environment, superClass *ClassInfo) *ClassInfo {
+	share(env)
+	return &ClassInfo{
+		definition: definition,
+		env:        env,
+		superClass: superClass,
+	}
+}
+
+// Name 类名
+func (c *ClassInfo) Name() string {
+	return c.definition.Name()
+}
+
+// SuperClass 获取父类
+func (c *ClassInfo) SuperClass() *ClassInfo {
+	return c.superClass
+}
+
+// Body 获取类体
+func (c *ClassInfo) Body() ClassBodyNode {
+	return c.definition.Body()
+}
+
+// String String方法
+func (c *ClassInfo) String() string {
+	return fmt.Sprintf("<class:%v>", c.Name())
+}
+
+// newObject 创建对象, 依次以父类、子类的类体初始化对象的环境, 类体在执行状态t中执行
+func (c *ClassInfo) newObject(t *thread) *Object {
+	env := NewNestedEnvironment(&threadEnv{c.env, t})
+	obj := NewObject(c, env)
+	env.PutNew("this", obj)
+	c.initObject(obj, env)
+	return obj
+}
+
+// initObject 以类体初始化对象, 方法在以对象环境为外层的新环境中执行, 其中super指向父类的方法
+func (c *ClassInfo) initObject(obj *Object, env *NestedEnvironment) {
+	methodEnv := NewNestedEnvironment(env)
+	if c.superClass != nil {
+		c.superClass.initObject(obj, env)
+		methodEnv.PutNew("super", NewSuper(obj, c.superClass, env))
+	}
+	c.Body().evalMembers(env, methodEnv)
+}
+
+// Object 类的实例, 字段和方法保存在对象自己的环境中
+type Object struct {
+	class *ClassInfo
+	env   *NestedEnvironment
+}
+
+// NewObject 创建Object
+func NewObject(class *ClassInfo, env *NestedEnvironment) *Object {
+	return &Object{class, env}
+}
+
+// Class 获取所属的类
+func (o *Object) Class() *ClassInfo {
+	return o.class
+}
+
+// Read 读取字段或方法
+func (o *Object) Read(member string) (interface{}, bool) {
+	v, ok, _ := o.env.lookup(member)
+	return v, ok
+}
+
+// Write 设置字段
+func (o *Object) Write(member string, value interface{}) {
+	o.env.PutNew(member, value)
+}
+
+// String String方法
+func (o *Object) String() string {
+	return fmt.Sprintf("<object:%v:%p>", o.class.Name(), o)
+}
+
+// Super super引用, 保存初始化子类前对象中的方法, 即父类定义的方法
+type Super struct {
+	obj     *Object
+	class   *ClassInfo
+	methods map[string]*Function
+}
+
+// NewSuper 创建Super, 记录env中当前的方法
+func NewSuper(obj *Object, class *ClassInfo, env *NestedEnvironment) *Super {
+	methods := make(map[string]*Function)
+	for name, v := range env.Values() {
+		if f, ok := v.(*Function); ok {
+			methods[name] = f
+		}
+	}
+	return &Super{obj, class, methods}
+}
+
+// Read 读取父类的方法
+func (s *Super) Read(member string) (interface{}, bool) {
+	f, ok := s.methods[member]
+	return f, ok
+}
+
+// String String方法
+func (s *Super) String() string {
+	return fmt.Sprintf("<super:%v>", s.class.Name())
+}
+
+// ClassStatementNode 类定义语句, 如class Name extends Base { ... }
+type ClassStatementNode struct {
+	BranchNode
+}
+
+// NewClassStatementNode 创建ClassStatementNode
+func NewClassStatementNode(list *list.ArrayList) ClassStatementNode {
+	return ClassStatementNode{NewBranchNode(list)}
+}
+
+// Name 类名
+func (c ClassStatementNode) Name() string {
+	n, _ := c.Child(0)
+	return n.(LeafNode).token.GetText()
+}
+
+// SuperClass 父类名, 没有时返回""
+func (c ClassStatementNode) SuperClass() string {
+	if c.ChildSize() < 3 {
+		return ""
+	}
+	n, _ := c.Child(1)
+	return n.(LeafNode).token.GetText()
+}
+
+// Body 获取类体
+func (c ClassStatementNode) Body() ClassBodyNode {
+	n, _ := c.Child(c.ChildSize() - 1)
+	return n.(ClassBodyNode)
+}
+
+// String 实现String接口
+func (c ClassStatementNode) String() string {
+	parent := ""
+	if super := c.SuperClass(); super != "" {
+		parent = " extends " + super
+	}
+	return fmt.Sprintf("(class %v%v %v)", c.Name(), parent, c.Body())
+}
+
+// Eval 获取计算值, 在环境中保存ClassInfo
+func (c ClassStatementNode) Eval(env Environment) interface{} {
+	var superClass *ClassInfo
+	if name := c.SuperClass(); name != "" {
+		n, _ := c.Child(1)
+		v, ok := env.Get(name)
+		if !ok {
+			panic(NewNameError(n, name))
+		}
+		if superClass, ok = v.(*ClassInfo); !ok {
+			panic(NewTypeError(n, "unknown super class: %v", name))
+		}
+	}
+	env.PutNew(c.Name(), NewClassInfo(c, env, superClass))
+	return c.Name()
+}
+
+// ClassBodyNode 类体
+type ClassBodyNode struct {
+	BranchNode
+}
+
+// NewClassBodyNode 创建ClassBodyNode
+func NewClassBodyNode(list *list.ArrayList) ClassBodyNode {
+	return ClassBodyNode{NewBranchNode(list)}
+}
+
+// evalMembers 在对象环境中执行类体, 字段赋值总是定义在对象环境中,
+// 方法以methodEnv为定义时的环境
+func (c ClassBodyNode) evalMembers(env Environment, methodEnv Environment) {
+	c.Children().For(func(k int, v interface{}) {
+		switch member := v.(type) {
+		case NullStatementNode:
+		case DefStatementNode:
+			f := NewFunction(member.Parameters(), member.Body(), methodEnv)
+			f.name = member.Name()
+			env.PutNew(member.Name(), f)
+		case BinaryExprNode:
+			if name, ok := member.Left().(VariableNode); ok && member.Operator() == "=" {
+				env.PutNew(name.Name(), member.Right().Eval(env))
+				return
+			}
+			member.Eval(env)
+		default:
+			member.(TreeNode).Eval(env)
+		}
+	})
+}
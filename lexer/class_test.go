@@ -0,0 +1,33 @@
+package lexer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClassDeclarations(t *testing.T) {
+	src := `class P {
+  const k = 1
+  var v = 2
+  let w
+  def bump() { v = v + k; v }
+}
+p = P.new
+println(p.k, p.v, p.w, p.bump())`
+	got, err := run(t, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "1 2 nil 3\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	for _, src := range []string{
+		"class P { const k = 1 }\nP.new.k = 2",
+		"class P { const k = 1\ndef set() { k = 2 } }\nP.new.set()",
+	} {
+		_, err := run(t, src)
+		if err == nil || !strings.Contains(err.Error(), "cannot assign to constant: k") {
+			t.Errorf("%q: got %v, want constant error", src, err)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package lexer
+
+import (
+	"fmt"
+	"simple-script-language/utils/list"
+)
+
+// DeclStatementNode 变量声明语句, 如var x = 1、let y、const z = 2
+type DeclStatementNode struct {
+	BranchNode
+	addr *address // 解析器分配的地址
+}
+
+// NewDeclStatementNode 创建DeclStatementNode
+func NewDeclStatementNode(list *list.ArrayList) DeclStatementNode {
+	return DeclStatementNode{NewBranchNode(list), &address{}}
+}
+
+// Kind 声明的类型, 为var、let或const
+func (d DeclStatementNode) Kind() string {
+	n, _ := d.Child(0)
+	return n.(LeafNode).token.GetText()
+}
+
+// Name 变量名
+func (d DeclStatementNode) Name() string {
+	n, _ := d.Child(1)
+	return n.(LeafNode).token.GetText()
+}
+
+// Initializer 初始值表达式, 省略时返回nil
+func (d DeclStatementNode) Initializer() TreeNode {
+	if d.ChildSize() < 3 {
+		return nil
+	}
+	n, _ := d.Child(2)
+	return n
+}
+
+// binding 定义方式
+func (d DeclStatementNode) binding() binding {
+	switch d.Kind() {
+	case "let":
+		return bindLet
+	case "const":
+		return bindConst
+	}
+	return bindVar
+}
+
+// String 实现String接口
+func (d DeclStatementNode) String() string {
+	if init := d.Initializer(); init != nil {
+		return fmt.Sprintf("(%v %v %v)", d.Kind(), d.Name(), init)
+	}
+	return fmt.Sprintf("(%v %v)", d.Kind(), d.Name())
+}
+
+// Eval 获取计算值, 定义变量并返回初始值, 省略初始值时为nil
+func (d DeclStatementNode) Eval(env Environment) interface{} {
+	var value interface{}
+	if init := d.Initializer(); init != nil {
+		value = init.Eval(env)
+	}
+	if e, ok := outerAt(env, d.addr.depth); ok {
+		switch d.addr.kind {
+		case addrLocal:
+			e.(*Frame).store(d.addr.slot, value)
+			return value
+		case addrGlobal:
+			env = e
+		}
+	}
+	env.PutNew(d.Name(), value)
+	if c, ok := env.(interface{ setConst(string, bool) }); ok {
+		c.setConst(d.Name(), d.Kind() == "const")
+	}
+	return value
+}
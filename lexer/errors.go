@@ -0,0 +1,375 @@
+package lexer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Position 源码中的位置
+type Position struct {
+	File   string // 文件名
+	Line   int    // 行号, 从1开始
+	Column int    // 列号(按字节计算), 从1开始, 0表示未知
+	Offset int    // 相对源码起始处的字节偏移量
+}
+
+// shift 同一行中向后移动n个字节后的位置
+func (p Position) shift(n int) Position {
+	p.Column += n
+	p.Offset += n
+	return p
+}
+
+// String 实现String接口, 格式为 file:line:column
+func (p Position) String() string {
+	file := p.File
+	if file == "" {
+		file = "<input>"
+	}
+	if p.Line <= 0 {
+		return file
+	}
+	if p.Column <= 0 {
+		return fmt.Sprintf("%v:%v", file, p.Line)
+	}
+	return fmt.Sprintf("%v:%v:%v", file, p.Line, p.Column)
+}
+
+// Span 源码中的区间
+type Span struct {
+	Start Position // 起始位置
+	End   Position // 结束位置(不包含)
+}
+
+// IsValid 是否为有效的区间
+func (s Span) IsValid() bool {
+	return s.Start.Line > 0
+}
+
+// String 实现String接口
+func (s Span) String() string {
+	return s.Start.String()
+}
+
+// SyntaxError 语法错误
+type SyntaxError struct {
+	Position          // 出错位置
+	End      Position // 出错区间的结束位置
+	Token    Token    // 出错的单词, 词法错误时为nil
+	Msg      string
+}
+
+// Error 实现error接口
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Position, e.Msg)
+}
+
+// ErrorList 错误恢复时得到的多个语法错误
+type ErrorList []*SyntaxError
+
+// Error 实现error接口
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	return fmt.Sprintf("%v (and %v more errors)", l[0], len(l)-1)
+}
+
+// Unwrap 获取第一个错误
+func (l ErrorList) Unwrap() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l[0]
+}
+
+// RuntimeError 运行时错误, 其他运行时错误类型均可通过errors.As转换为该类型
+type RuntimeError struct {
+	Position           // 出错位置
+	End       Position // 出错区间的结束位置
+	Token     Token    // 出错节点的第一个单词
+	Kind      string   // 错误类型名称
+	Msg       string
+	Traceback Traceback // 出错时的调用栈, 由捕获错误处记录
+}
+
+// Error 实现error接口
+func (e *RuntimeError) Error() string {
+	return fmt.Sprintf("%v: %v: %v", e.Position, e.Kind, e.Msg)
+}
+
+// locate 没有位置时设置出错位置
+func (e *RuntimeError) locate(span Span, token Token) {
+	if e.Line <= 0 {
+		e.Position, e.End, e.Token = span.Start, span.End, token
+	}
+}
+
+// TypeError 类型错误
+type TypeError struct {
+	RuntimeError
+}
+
+// Unwrap 获取RuntimeError
+func (e *TypeError) Unwrap() error {
+	return &e.RuntimeError
+}
+
+// NameError 变量名未定义错误
+type NameError struct {
+	RuntimeError
+	Name string // 变量名
+}
+
+// Unwrap 获取RuntimeError
+func (e *NameError) Unwrap() error {
+	return &e.RuntimeError
+}
+
+// ArityError 函数参数个数错误
+type ArityError struct {
+	RuntimeError
+	Expected int // 需要的参数个数
+	Actual   int // 实际的参数个数
+}
+
+// Unwrap 获取RuntimeError
+func (e *ArityError) Unwrap() error {
+	return &e.RuntimeError
+}
+
+// ZeroDivisionError 除数为0的错误
+type ZeroDivisionError struct {
+	RuntimeError
+}
+
+// Unwrap 获取RuntimeError
+func (e *ZeroDivisionError) Unwrap() error {
+	return &e.RuntimeError
+}
+
+// IndexError 下标越界的错误
+type IndexError struct {
+	RuntimeError
+	Index interface{} // 下标
+	Size  int         // 长度
+}
+
+// Unwrap 获取RuntimeError
+func (e *IndexError) Unwrap() error {
+	return &e.RuntimeError
+}
+
+// KeyError 映射中不存在键的错误
+type KeyError struct {
+	RuntimeError
+	Key interface{} // 键
+}
+
+// Unwrap 获取RuntimeError
+func (e *KeyError) Unwrap() error {
+	return &e.RuntimeError
+}
+
+// NativeError 宿主函数返回的错误
+type NativeError struct {
+	RuntimeError
+	Err error // 宿主函数返回的原始错误
+}
+
+// Unwrap 获取宿主函数返回的原始错误
+func (e *NativeError) Unwrap() error {
+	return e.Err
+}
+
+// As 可通过errors.As转换为*RuntimeError
+func (e *NativeError) As(target interface{}) bool {
+	if t, ok := target.(**RuntimeError); ok {
+		*t = &e.RuntimeError
+		return true
+	}
+	return false
+}
+
+// LimitExceeded 超出执行限制或执行被取消的错误, 不能被脚本捕获
+type LimitExceeded struct {
+	RuntimeError
+	Limit string // 超出的限制: step、call depth、allocation, 被取消时为context
+	Err   error  // 被取消时为context的错误
+}
+
+// Unwrap 获取RuntimeError
+func (e *LimitExceeded) Unwrap() error {
+	return &e.RuntimeError
+}
+
+// Is 被取消时与context的错误相同
+func (e *LimitExceeded) Is(target error) bool {
+	return e.Err != nil && errors.Is(e.Err, target)
+}
+
+// newRuntimeError 创建指定节点处的运行时错误
+func newRuntimeError(kind string, node TreeNode, format string, a ...interface{}) RuntimeError {
+	err := RuntimeError{Kind: kind, Msg: fmt.Sprintf(format, a...)}
+	if node != nil {
+		err.Token = firstToken(node)
+		span := node.Span()
+		err.Position, err.End = span.Start, span.End
+	}
+	return err
+}
+
+// NewRuntimeError 创建RuntimeError
+func NewRuntimeError(node TreeNode, format string, a ...interface{}) *RuntimeError {
+	err := newRuntimeError("RuntimeError", node, format, a...)
+	return &err
+}
+
+// NewTypeError 创建TypeError
+func NewTypeError(node TreeNode, format string, a ...interface{}) *TypeError {
+	return &TypeError{newRuntimeError("TypeError", node, format, a...)}
+}
+
+// NewNameError 创建NameError
+func NewNameError(node TreeNode, name string) *NameError {
+	return &NameError{newRuntimeError("NameError", node, "undefined name: %v", name), name}
+}
+
+// NewArityError 创建ArityError
+func NewArityError(node TreeNode, expected, actual int) *ArityError {
+	return &ArityError{
+		newRuntimeError("ArityError", node, "bad number of arguments: expected %v, got %v", expected, actual),
+		expected,
+		actual,
+	}
+}
+
+// NewZeroDivisionError 创建ZeroDivisionError
+func NewZeroDivisionError(node TreeNode) *ZeroDivisionError {
+	return &ZeroDivisionError{newRuntimeError("ZeroDivisionError", node, "division by zero")}
+}
+
+// NewIndexError 创建IndexError
+func NewIndexError(node TreeNode, index interface{}, size int) *IndexError {
+	err := newRuntimeError("IndexError", node, "index %v out of range [0, %v)", ToString(index), size)
+	return &IndexError{RuntimeError: err, Index: index, Size: size}
+}
+
+// NewKeyError 创建KeyError
+func NewKeyError(node TreeNode, key interface{}) *KeyError {
+	err := newRuntimeError("KeyError", node, "key not found: %v", toString(key, true, nil))
+	return &KeyError{RuntimeError: err, Key: key}
+}
+
+// NewNativeError 创建NativeError
+func NewNativeError(node TreeNode, name string, err error) *NativeError {
+	return &NativeError{newRuntimeError("NativeError", node, "%v: %v", name, err), err}
+}
+
+// NewLimitExceeded 创建超出执行限制的LimitExceeded
+func NewLimitExceeded(node TreeNode, limit string, max int) *LimitExceeded {
+	err := newRuntimeError("LimitExceeded", node, "%v limit exceeded: %v", limit, max)
+	return &LimitExceeded{RuntimeError: err, Limit: limit}
+}
+
+// newCanceledError 创建执行被取消的LimitExceeded
+func newCanceledError(node TreeNode, err error) *LimitExceeded {
+	return &LimitExceeded{newRuntimeError("LimitExceeded", node, "execution canceled: %v", err), "context", err}
+}
+
+// RecoveredError 将recover得到的内容转换为error, 非本包的错误包装为RuntimeError
+func RecoveredError(r interface{}) error {
+	switch e := r.(type) {
+	case *SyntaxError:
+		return e
+	case *RuntimeError:
+		return e
+	case *TypeError:
+		return e
+	case *NameError:
+		return e
+	case *ArityError:
+		return e
+	case *ZeroDivisionError:
+		return e
+	case *IndexError:
+		return e
+	case *KeyError:
+		return e
+	case *NativeError:
+		return e
+	case *ThrowError:
+		return e
+	case *LimitExceeded:
+		return e
+	case error:
+		return NewRuntimeError(nil, "%v", e)
+	}
+	return NewRuntimeError(nil, "%v", r)
+}
+
+// firstToken 获取节点中的第一个单词
+func firstToken(node TreeNode) Token {
+	if n, ok := node.(interface{ firstToken() Token }); ok {
+		return n.firstToken()
+	}
+	return nil
+}
+
+// FormatError 格式化错误信息, 附带出错的源码行并在出错区间下方标出"^", 运行时错误同时附带调用栈
+func FormatError(err error, src string) string {
+	msg := formatSource(err, src)
+	var re *RuntimeError
+	if errors.As(err, &re) && re.Traceback != nil {
+		msg += "\n" + re.Traceback.String()
+	}
+	return msg
+}
+
+// formatSource 格式化错误信息, 附带出错的源码行并在出错区间下方标出"^"
+func formatSource(err error, src string) string {
+	var start, end Position
+	var se *SyntaxError
+	var re *RuntimeError
+	if errors.As(err, &se) {
+		start, end = se.Position, se.End
+	} else if errors.As(err, &re) {
+		start, end = re.Position, re.End
+	} else {
+		return err.Error()
+	}
+	lines := strings.Split(src, "\n")
+	if start.Line <= 0 || start.Line > len(lines) || start.Column <= 0 {
+		return err.Error()
+	}
+	line := strings.TrimRight(lines[start.Line-1], "\r")
+	col := start.Column - 1
+	if col > len(line) {
+		col = len(line)
+	}
+	width := 1
+	if end.Line == start.Line && end.Column > start.Column && end.Column-1 <= len(line) {
+		width = utf8.RuneCountInString(line[col : end.Column-1])
+	}
+	var buf strings.Builder
+	buf.WriteString(err.Error())
+
+	buf.WriteString("\n    ")
+	buf.WriteString(line)
+	buf.WriteString("\n    ")
+	// 保留制表符以便与源码对齐
+	for _, c := range line[:col] {
+		if c == '\t' {
+			buf.WriteByte('\t')
+		} else {
+			buf.WriteByte(' ')
+		}
+	}
+	buf.WriteString(strings.Repeat("^", width))
+	return buf.String()
+}
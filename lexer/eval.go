@@ -1,38 +1,54 @@
 package lexer
 
+import (
+	"fmt"
+	"os"
+	"simple-script-language/utils/list"
+	"strconv"
+	"strings"
+	"sync"
+)
+
 // Environment 环境对象接口
 type Environment interface {
 	Put(name string, value interface{})    // 保存对象
 	PutNew(name string, value interface{}) // 添加新对象
-	Get(name string) interface{}           // 获取值
+	Get(name string) (interface{}, bool)   // 获取值, 未定义时返回false
 	Where(name string) Environment         // 在所有作用域中获取值
 }
 
-// BasicEnvironment 基础环境对象实现
+// BasicEnvironment 基础环境对象实现, 复制后仍共享同一组变量, 可以在多个goroutine中使用
 type BasicEnvironment struct {
 	values map[string]interface{}
+	mu     *sync.RWMutex
 }
 
 // NewBasicEnv 创建BasicEnvironment对象
 func NewBasicEnv() BasicEnvironment {
 	return BasicEnvironment{
 		make(map[string]interface{}),
+		&sync.RWMutex{},
 	}
 }
 
 // Put 保存对象
 func (b BasicEnvironment) Put(name string, value interface{}) {
-	b.values[name] = value
+	b.PutNew(name, value)
 }
 
 // PutNew 保存对象
 func (b BasicEnvironment) PutNew(name string, value interface{}) {
+	b.mu.Lock()
 	b.values[name] = value
+	b.mu.Unlock()
 }
 
 // Get 获取值
-func (b BasicEnvironment) Get(name string) interface{} {
-	return b.values[name]
+func (b BasicEnvironment) Get(name string) (interface{}, bool) {
+	b.mu.RLock()
+	v, ok := b.values[name]
+	b.mu.RUnlock()
+	return v, ok
 }
 
 // Where 在所有作用域中获取值
@@ -40,48 +56,163 @@ func (b BasicEnvironment) Where(name string) Environment {
 	return nil
 }
 
-const (
-	TRUE  = 1
-	FALSE = 0
-)
+// Truthy 判断条件是否成立, nil、false、0和空字符串为假, 其他值为真
+func Truthy(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case int:
+		return v != 0
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	}
+	return true
+}
+
+// ToString 获取值的字符串形式
+func ToString(v interface{}) string {
+	return toString(v, false, nil)
+}
+
+// toString 获取值的字符串形式, quote为true时字符串带引号(作为数组或映射的元素时),
+// seen记录正在输出的数组和映射, 避免包含自身时无限递归
+func toString(v interface{}, quote bool, seen map[interface{}]bool) string {
+	switch v := v.(type) {
+	case nil:
+		return "nil"
+	case float64:
+		return formatFloat(v)
+	case string:
+		if quote {
+			return strconv.Quote(v)
+		}
+		return v
+	case *list.ArrayList:
+		if seen[v] {
+			return "[...]"
+		}
+		if seen == nil {
+			seen = make(map[interface{}]bool)
+		}
+		seen[v] = true
+		defer delete(seen, v)
+		elements := make([]string, 0, v.Size())
+		v.For(func(k int, item interface{}) {
+			elements = append(elements, toString(item, true, seen))
+		})
+		return "[" + strings.Join(elements, ", ") + "]"
+	case *Map:
+		if seen[v] {
+			return "{...}"
+		}
+		if seen == nil {
+			seen = make(map[interface{}]bool)
+		}
+		seen[v] = true
+		defer delete(seen, v)
+		entries := make([]string, 0, v.Size())
+		v.For(func(key interface{}, value interface{}) {
+			entries = append(entries, toString(key, true, seen)+": "+toString(value, true, seen))
+		})
+		return "{" + strings.Join(entries, ", ") + "}"
+	}
+	return fmt.Sprint(v)
+}
 
-// NestedEnvironment
+// NestedEnvironment 嵌套的环境, 可以在多个goroutine中共享
 type NestedEnvironment struct {
-	values map[string]interface{} // 当前作用域变量
-	outer  Environment            // 外层作用域变量
+	mu      sync.RWMutex
+	values  map[string]interface{} // 当前作用域变量
+	outer   Environment            // 外层作用域变量
+	consts  map[string]bool        // 以const定义的变量
+	escaped bool                   // 是否可能被spawn创建的任务访问, 此后保存的值同样以escape标记
 }
 
-// NewNestedEnvironment 创建NestedEnvironment对象
-func NewNestedEnvironment(environment Environment) NestedEnvironment {
-	return NestedEnvironment{
-		make(map[string]interface{}),
-		environment,
+// NewNestedEnvironment 创建NestedEnvironment对象, environment为nil时以包含内置函数的环境为外层,
+// 内置函数输出到标准输出
+func NewNestedEnvironment(environment Environment) *NestedEnvironment {
+	if environment == nil {
+		environment = NewBuiltinEnv(os.Stdout)
+	}
+	return &NestedEnvironment{
+		values: make(map[string]interface{}),
+		outer:  environment,
+		consts: make(map[string]bool),
 	}
 }
 
+// isConst 变量是否为常量
+func (n *NestedEnvironment) isConst(name string) bool {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.consts[name]
+}
+
+// setConst 设置变量是否为常量
+func (n *NestedEnvironment) setConst(name string, constant bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if constant {
+		n.consts[name] = true
+	} else {
+		delete(n.consts, name)
+	}
+}
+
+// Outer 外层变量
+func (n *NestedEnvironment) Outer() Environment {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.outer
+}
+
 // SetOuter 设置外层变量
-func (n NestedEnvironment) SetOuter(environment Environment) {
+func (n *NestedEnvironment) SetOuter(environment Environment) {
+	if n.escaped {
+		escapeEnv(environment)
+	}
+	n.mu.Lock()
 	n.outer = environment
+	n.mu.Unlock()
 }
 
 // PutNew 保存新变量
-func (n NestedEnvironment) PutNew(name string, value interface{}) {
+func (n *NestedEnvironment) PutNew(name string, value interface{}) {
+	if n.escaped {
+		escape(value)
+	}
+	n.mu.Lock()
 	n.values[name] = value
+	n.mu.Unlock()
+}
+
+// lookup 获取当前作用域中的变量及外层作用域
+func (n *NestedEnvironment) lookup(name string) (interface{}, bool, Environment) {
+	n.mu.RLock()
+	v, ok := n.values[name]
+	outer := n.outer
+	n.mu.RUnlock()
+	return v, ok, outer
 }
 
 // Where 在所有作用域中获取值
-func (n NestedEnvironment) Where(name string) Environment {
-	if n.values[name] != nil {
+func (n *NestedEnvironment) Where(name string) Environment {
+	_, ok, outer := n.lookup(name)
+	if ok {
 		return n
 	}
-	if n.outer == nil {
+	if outer == nil {
 		return nil
 	}
-	return n.outer.Where(name)
+	return outer.Where(name)
 }
 
 // Put 保存对象
-func (n NestedEnvironment) Put(name string, value interface{}) {
+func (n *NestedEnvironment) Put(name string, value interface{}) {
 	e := n.Where(name)
 	if e == nil {
 		e = n
@@ -90,10 +221,21 @@ func (n NestedEnvironment) Put(name string, value interface{}) {
 }
 
 // Get 获取值
-func (n NestedEnvironment) Get(name string) interface{} {
-	v := n.values[name]
-	if v == nil && n.outer != nil {
-		return n.outer.Get(name)
+func (n *NestedEnvironment) Get(name string) (interface{}, bool) {
+	v, ok, outer := n.lookup(name)
+	if !ok && outer != nil {
+		return outer.Get(name)
+	}
+	return v, ok
+}
+
+// Values 当前作用域中的所有变量
+func (n *NestedEnvironment) Values() map[string]interface{} {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	values := make(map[string]interface{}, len(n.values))
+	for k, v := range n.values {
+		values[k] = v
 	}
-	return v
+	return values
 }
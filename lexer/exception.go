@@ -0,0 +1,303 @@
+package lexer
+
+import (
+	"errors"
+	"fmt"
+	"simple-script-language/utils/list"
+)
+
+// ErrorValue 脚本中的错误值, catch得到的值均为该类型, 也可以由内置函数error创建
+type ErrorValue struct {
+	Kind    string      // 错误类型名称, 如TypeError
+	Message string      // 错误信息
+	Stack   Traceback   // 出错时的调用栈
+	Value   interface{} // throw抛出的不是错误值时为抛出的值
+	Err     error       // 运行时错误对应的Go错误
+}
+
+// NewErrorValue 创建ErrorValue
+func NewErrorValue(kind, message string) *ErrorValue {
+	return &ErrorValue{Kind: kind, Message: message}
+}
+
+// errorValueOf 以运行时错误创建ErrorValue
+func errorValueOf(err error, re *RuntimeError) *ErrorValue {
+	e := NewErrorValue(re.Kind, re.Msg)
+	e.Stack = re.Traceback
+	e.Err = err
+	return e
+}
+
+// Error 实现error接口
+func (e *ErrorValue) Error() string {
+	return fmt.Sprintf("%v: %v", e.Kind, e.Message)
+}
+
+// String 实现String接口
+func (e *ErrorValue) String() string {
+	return e.Error()
+}
+
+// TypeName 类型名
+func (e *ErrorValue) TypeName() string {
+	return "error"
+}
+
+// Read 读取成员message、kind、value, stack为调用栈各层的"位置 in 函数名", traceback为格式化的调用栈
+func (e *ErrorValue) Read(member string) (interface{}, bool) {
+	switch member {
+	case "message":
+		return e.Message, true
+	case "kind":
+		return e.Kind, true
+	case "stack":
+		stack := list.New(len(e.Stack))
+		for _, f := range e.Stack {
+			stack.Add(fmt.Sprintf("%v in %v", f.Position, f.Function))
+		}
+		return stack, true
+	case "traceback":
+		return fmt.Sprintf("%v\n%v", e.Stack, e), true
+	case "value":
+		return e.Value, true
+	}
+	return nil, false
+}
+
+// ThrowError throw语句抛出的错误
+type ThrowError struct {
+	RuntimeError
+	Value *ErrorValue // 抛出的错误值
+}
+
+// Unwrap 获取RuntimeError
+func (e *ThrowError) Unwrap() error {
+	return &e.RuntimeError
+}
+
+// NewThrowError 创建ThrowError, 抛出的值不是错误值时以其字符串形式为错误信息
+func NewThrowError(node TreeNode, value interface{}) *ThrowError {
+	e, ok := value.(*ErrorValue)
+	if !ok {
+		e = NewErrorValue("Error", ToString(value))
+		e.Value = value
+	}
+	err := &ThrowError{newRuntimeError(e.Kind, node, "%v", e.Message), e}
+	// 再次抛出捕获的错误时保留原来的调用栈
+	err.Traceback = e.Stack
+	return err
+}
+
+// catchError 将try代码块中recover得到的内容转换为错误值, 不能被脚本捕获(如LimitExceeded)时返回false
+func catchError(r interface{}) (*ErrorValue, bool) {
+	if t, ok := r.(*ThrowError); ok {
+		if t.Value.Stack == nil {
+			t.Value.Stack = t.Traceback
+		}
+		return t.Value, true
+	}
+	err, ok := r.(error)
+	if !ok {
+		return nil, false
+	}
+	var re *RuntimeError
+	var le *LimitExceeded
+	if !errors.As(err, &re) || errors.As(err, &le) {
+		return nil, false
+	}
+	return errorValueOf(err, re), true
+}
+
+// ThrowStatementNode throw语句, 第一个子节点为throw关键字
+type ThrowStatementNode struct {
+	BranchNode
+}
+
+// NewThrowStatementNode 创建ThrowStatementNode
+func NewThrowStatementNode(list *list.ArrayList) ThrowStatementNode {
+	return ThrowStatementNode{NewBranchNode(list)}
+}
+
+// Value 抛出的表达式
+func (t ThrowStatementNode) Value() TreeNode {
+	n, _ := t.Child(1)
+	return n
+}
+
+// String 实现String接口
+func (t ThrowStatementNode) String() string {
+	return fmt.Sprintf("(throw %v)", t.Value())
+}
+
+// Eval 抛出错误
+func (t ThrowStatementNode) Eval(env Environment) interface{} {
+	panic(NewThrowError(t, t.Value().Eval(env)))
+}
+
+// CatchClauseNode catch部分, 省略时没有子节点
+type CatchClauseNode struct {
+	BranchNode
+	layout *layout // 错误变量所在帧的变量, 由解析器填写
+}
+
+// NewCatchClauseNode 创建CatchClauseNode
+func NewCatchClauseNode(list *list.ArrayList) CatchClauseNode {
+	return CatchClauseNode{NewBranchNode(list), &layout{}}
+}
+
+// Empty 是否省略了catch
+func (c CatchClauseNode) Empty() bool {
+	return c.ChildSize() == 0
+}
+
+// Name 保存错误值的变量名
+func (c CatchClauseNode) Name() string {
+	n, _ := c.Child(0)
+	return n.(LeafNode).token.GetText()
+}
+
+// Body 代码块
+func (c CatchClauseNode) Body() TreeNode {
+	n, _ := c.Child(1)
+	return n
+}
+
+// String 实现String接口
+func (c CatchClauseNode) String() string {
+	if c.Empty() {
+		return ""
+	}
+	return fmt.Sprintf(" catch (%v) %v", c.Name(), c.Body())
+}
+
+// Eval 在新的环境中定义错误变量后执行代码块
+func (c CatchClauseNode) Eval(env Environment) interface{} {
+	return c.handle(env, nil)
+}
+
+// handle 处理错误值e
+func (c CatchClauseNode) handle(env Environment, e *ErrorValue) interface{} {
+	if c.layout.resolved {
+		frame := newFrame(c.layout, env)
+		frame.values[0] = e
+		return c.Body().Eval(frame)
+	}
+	nested := NewNestedEnvironment(env)
+	nested.PutNew(c.Name(), e)
+	return c.Body().Eval(nested)
+}
+
+// FinallyClauseNode finally部分, 省略时没有子节点
+type FinallyClauseNode struct {
+	BranchNode
+}
+
+// NewFinallyClauseNode 创建FinallyClauseNode
+func NewFinallyClauseNode(list *list.ArrayList) FinallyClauseNode {
+	return FinallyClauseNode{NewBranchNode(list)}
+}
+
+// Body 代码块, 省略时返回nil
+func (f FinallyClauseNode) Body() TreeNode {
+	if f.ChildSize() == 0 {
+		return nil
+	}
+	n, _ := f.Child(0)
+	return n
+}
+
+// String 实现String接口
+func (f FinallyClauseNode) String() string {
+	if b := f.Body(); b != nil {
+		return fmt.Sprintf(" finally %v", b)
+	}
+	return ""
+}
+
+// Eval 执行代码块
+func (f FinallyClauseNode) Eval(env Environment) interface{} {
+	return evalOptional(env, f.Body())
+}
+
+// TryStatementNode try语句, 如try { ... } catch (e) { ... } finally { ... }, 第一个子节点为try关键字
+type TryStatementNode struct {
+	BranchNode
+}
+
+// NewTryStatementNode 创建TryStatementNode
+func NewTryStatementNode(list *list.ArrayList) TryStatementNode {
+	return TryStatementNode{NewBranchNode(list)}
+}
+
+// Body try代码块
+func (t TryStatementNode) Body() TreeNode {
+	n, _ := t.Child(1)
+	return n
+}
+
+// Catch catch部分
+func (t TryStatementNode) Catch() CatchClauseNode {
+	n, _ := t.Child(2)
+	return n.(CatchClauseNode)
+}
+
+// Finally finally部分
+func (t TryStatementNode) Finally() FinallyClauseNode {
+	n, _ := t.Child(3)
+	return n.(FinallyClauseNode)
+}
+
+// String 实现String接口
+func (t TryStatementNode) String() string {
+	return fmt.Sprintf("(try %v%v%v)", t.Body(), t.Catch(), t.Finally())
+}
+
+// Eval 获取计算值, 运行时错误及throw抛出的错误由catch处理;
+// finally除超出执行限制外总会执行, 其中的return、break、continue取代原来的结果及未处理的错误
+func (t TryStatementNode) Eval(env Environment) (result interface{}) {
+	if t.Finally().Body() != nil {
+		th := currentThread(env)
+		depth := len(th.calls)
+		defer func() {
+			r := recover()
+			if r != nil {
+				th.unwind(r, depth)
+				if _, ok := r.(*LimitExceeded); ok {
+					// 超出执行限制时不再执行finally
+					panic(r)
+				}
+			}
+			if f := t.Finally().Eval(env); isJump(f) {
+				result = f
+				return
+			}
+			if r != nil {
+				panic(r)
+			}
+		}()
+	}
+	if t.Catch().Empty() {
+		return t.Body().Eval(env)
+	}
+	value, e := t.protect(env, currentThread(env))
+	if e == nil {
+		return value
+	}
+	return t.Catch().handle(env, e)
+}
+
+// protect 执行try代码块, 返回捕获的错误值, 不能被脚本捕获的错误继续抛出; 调用栈恢复至th中进入try时的层数
+func (t TryStatementNode) protect(env Environment, th *thread) (result interface{}, caught *ErrorValue) {
+	depth := len(th.calls)
+	defer func() {
+		if r := recover(); r != nil {
+			th.unwind(r, depth)
+			e, ok := catchError(r)
+			if !ok {
+				panic(r)
+			}
+			caught = e
+		}
+	}()
+	return t.Body().Eval(env), nil
+}
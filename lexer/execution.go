@@ -0,0 +1,38 @@
+package lexer
+
+import "context"
+
+// Execution 一次执行的状态, 供虚拟机等其他执行方式使用与解释执行相同的执行限制及取消
+type Execution struct {
+	t *thread
+}
+
+// NewExecution 创建受limits限制、ctx取消时停止的执行
+func NewExecution(ctx context.Context, limits Limits) *Execution {
+	return &Execution{newThread(ctx, limits, newTaskSet(ctx))}
+}
+
+// Step 执行一步, 超出步数限制或已取消时在node处抛出LimitExceeded
+func (e *Execution) Step(node TreeNode) {
+	e.t.step(node)
+}
+
+// Alloc 分配n个单位, 超出限制时在node处抛出LimitExceeded
+func (e *Execution) Alloc(node TreeNode, n int) {
+	e.t.alloc(node, n)
+}
+
+// AllocString 按字节数计数创建的长度为size的字符串, 超出限制时在node处抛出LimitExceeded
+func (e *Execution) AllocString(node TreeNode, size int) {
+	e.t.allocString(node, size)
+}
+
+// CallNative 在该执行中检查参数个数并调用宿主函数, 内置函数受执行限制及取消影响
+func (e *Execution) CallNative(f *NativeFunction, node TreeNode, args []Value) Value {
+	return f.run(e.t, node, args)
+}
+
+// Finish 结束执行, 取消尚未结束的任务并等待其退出, 未被等待的任务出错时返回该任务的错误
+func (e *Execution) Finish() error {
+	return e.t.tasks.finish()
+}
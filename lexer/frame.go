@@ -0,0 +1,287 @@
+package lexer
+
+import (
+	"simple-script-language/utils/list"
+	"sync"
+)
+
+// 变量地址的类型
+const (
+	addrDynamic = iota // 未解析或位于类体中, 按名称在作用域链中查找
+	addrLocal          // 外层第depth个帧中的第slot个变量
+	addrGlobal         // 外层第depth个帧之外的全局环境中的变量, 按名称查找
+)
+
+// address 解析器为变量分配的静态地址
+type address struct {
+	kind  int
+	depth int
+	slot  int
+}
+
+// layout 作用域中的变量及其下标, 由解析器填写, 创建帧时据此分配数组
+type layout struct {
+	slots    map[string]int
+	names    []string
+	consts   map[string]bool // 以const定义的变量
+	function bool            // 是否为函数的帧, 否则为代码块的帧
+	resolved bool
+}
+
+// reset 清空变量
+func (l *layout) reset() {
+	l.slots = make(map[string]int)
+	l.names = l.names[:0]
+	l.consts = make(map[string]bool)
+	l.function = false
+	l.resolved = true
+}
+
+// add 添加变量, 已存在时返回原下标
+func (l *layout) add(name string) int {
+	if slot, ok := l.slots[name]; ok {
+		return slot
+	}
+	l.slots[name] = len(l.names)
+	l.names = append(l.names, name)
+	return len(l.names) - 1
+}
+
+// unsetValue 尚未赋值的变量
+type unsetValue struct{}
+
+// unset 尚未赋值的变量的值
+var unset interface{} = unsetValue{}
+
+// Frame 以数组保存变量的作用域, 变量按解析器分配的下标访问
+type Frame struct {
+	mu      sync.RWMutex
+	shared  bool // 是否被函数或类引用, 引用后可能在多个goroutine中访问, 变量加锁访问
+	escaped bool // 是否可能被spawn创建的任务访问, 此后保存的值同样以escape标记
+	layout  *layout
+	values  []interface{}
+	outer   Environment
+	vars    map[string]interface{} // 解析时未知的变量, 如类的方法中赋值的变量
+	thread  *thread                // 所属的执行状态
+}
+
+// newFrame 创建Frame, 变量初始时均未赋值; 代码块的帧与外层属于同一执行状态, 函数的帧由调用者设置执行状态
+func newFrame(l *layout, outer Environment) *Frame {
+	values := make([]interface{}, len(l.names))
+	for i := range values {
+		values[i] = unset
+	}
+	frame := &Frame{layout: l, values: values, outer: outer}
+	switch o := outer.(type) {
+	case *Frame:
+		frame.thread = o.thread
+	case *threadEnv:
+		frame.thread = o.thread
+	}
+	return frame
+}
+
+// load 获取第slot个变量的值
+func (f *Frame) load(slot int) interface{} {
+	if !f.shared {
+		return f.values[slot]
+	}
+	f.mu.RLock()
+	v := f.values[slot]
+	f.mu.RUnlock()
+	return v
+}
+
+// store 保存第slot个变量的值
+func (f *Frame) store(slot int, value interface{}) {
+	if !f.shared {
+		f.values[slot] = value
+		return
+	}
+	if f.escaped {
+		escape(value)
+	}
+	f.mu.Lock()
+	f.values[slot] = value
+	f.mu.Unlock()
+}
+
+// snapshot 复制所有变量的值到values
+func (f *Frame) snapshot(values []interface{}) {
+	if f.shared {
+		f.mu.RLock()
+		defer f.mu.RUnlock()
+	}
+	copy(values, f.values)
+}
+
+// share 标记env及其外层的帧被函数或类引用; 帧只在创建它的goroutine中标记,
+// 被标记前其他goroutine无法访问该帧, 因此标记本身不需要加锁
+func share(env Environment) {
+	for {
+		switch e := env.(type) {
+		case *Frame:
+			if e.shared {
+				// 外层的帧已在此前标记
+				return
+			}
+			e.shared = true
+			env = e.outer
+		case *threadEnv:
+			env = e.Environment
+		case *NestedEnvironment:
+			env = e.Outer()
+		default:
+			return
+		}
+	}
+}
+
+// escape 标记v可能被spawn创建的任务访问: 从v可以访问到的数组此后加锁访问, 帧、环境及映射此后保存的值同样被标记。
+// 与share相同, 未标记的值只在当前goroutine中访问, 因此标记本身不需要加锁
+func escape(v interface{}) {
+	switch v := v.(type) {
+	case *list.ArrayList:
+		v.Share(escape)
+	case *Map:
+		v.escape()
+	case *Function:
+		escapeEnv(v.env)
+	case *ClassInfo:
+		escapeEnv(v.env)
+		if v.superClass != nil {
+			escape(v.superClass)
+		}
+	case *Object:
+		escapeEnv(v.env)
+		escape(v.class)
+	case *Super:
+		escape(v.obj)
+	case *ErrorValue:
+		escape(v.Value)
+	}
+}
+
+// escapeEnv 标记env及其外层的环境可能被spawn创建的任务访问, 被标记的帧同时加锁访问
+func escapeEnv(env Environment) {
+	for {
+		switch e := env.(type) {
+		case *Frame:
+			if e.escaped {
+				return
+			}
+			e.shared, e.escaped = true, true
+			for _, v := range e.values {
+				escape(v)
+			}
+			for _, v := range e.vars {
+				escape(v)
+			}
+			env = e.outer
+		case *threadEnv:
+			env = e.Environment
+		case *NestedEnvironment:
+			if e.escaped {
+				return
+			}
+			e.escaped = true
+			for _, v := range e.Values() {
+				escape(v)
+			}
+			env = e.Outer()
+		default:
+			return
+		}
+	}
+}
+
+// PutNew 保存新变量
+func (f *Frame) PutNew(name string, value interface{}) {
+	if slot, ok := f.layout.slots[name]; ok {
+		f.store(slot, value)
+		return
+	}
+	if f.escaped {
+		escape(value)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.vars == nil {
+		f.vars = make(map[string]interface{})
+	}
+	f.vars[name] = value
+}
+
+// Where 在所有作用域中获取值
+func (f *Frame) Where(name string) Environment {
+	if _, ok := f.layout.slots[name]; ok {
+		return f
+	}
+	f.mu.RLock()
+	_, ok := f.vars[name]
+	f.mu.RUnlock()
+	if ok {
+		return f
+	}
+	if f.outer == nil {
+		return nil
+	}
+	return f.outer.Where(name)
+}
+
+// Put 保存对象
+func (f *Frame) Put(name string, value interface{}) {
+	e := f.Where(name)
+	if e == nil {
+		e = f
+	}
+	e.PutNew(name, value)
+}
+
+// Get 获取值
+func (f *Frame) Get(name string) (interface{}, bool) {
+	if slot, ok := f.layout.slots[name]; ok {
+		v := f.load(slot)
+		return v, v != unset
+	}
+	f.mu.RLock()
+	v, ok := f.vars[name]
+	f.mu.RUnlock()
+	if ok {
+		return v, true
+	}
+	if f.outer != nil {
+		return f.outer.Get(name)
+	}
+	return nil, false
+}
+
+// isConst 变量是否为常量
+func (f *Frame) isConst(name string) bool {
+	return f.layout.consts[name]
+}
+
+// constEnv 记录常量的环境
+type constEnv interface {
+	isConst(name string) bool
+}
+
+// isConst 环境中可见的变量name是否为常量
+func isConst(env Environment, name string) bool {
+	if c, ok := env.Where(name).(constEnv); ok {
+		return c.isConst(name)
+	}
+	return false
+}
+
+// outerAt 获取env外层第depth个环境, 中间的环境均应为帧
+func outerAt(env Environment, depth int) (Environment, bool) {
+	for i := 0; i < depth; i++ {
+		f, ok := env.(*Frame)
+		if !ok {
+			return nil, false
+		}
+		env = f.outer
+	}
+	return env, true
+}
@@ -7,10 +7,12 @@ type Function struct {
 	parameters ParameterListNode  // 参数列表
 	body       BlockStatementNode // 函数体
 	env        Environment        // 环境变量
+	name       string             // 函数名, 匿名函数为空
 }
 
-// NewFunction 创建Function对象
+// NewFunction 创建Function对象, 函数引用的帧此后加锁访问
 func NewFunction(parameters ParameterListNode, body BlockStatementNode, env Environment) *Function {
+	share(env)
 	return &Function{
 		parameters: parameters,
 		body:       body,
@@ -18,6 +20,14 @@ func NewFunction(parameters ParameterListNode, body BlockStatementNode, env Envi
 	}
 }
 
+// Name 函数名, 用于调用栈, 匿名函数返回<fun>
+func (f *Function) Name() string {
+	if f.name == "" {
+		return "<fun>"
+	}
+	return f.name
+}
+
 // GetParameters 获取参数列表
 func (f *Function) Parameters() ParameterListNode {
 	return f.parameters
@@ -28,12 +38,71 @@ func (f *Function) Body() BlockStatementNode {
 	return f.body
 }
 
-// makeEnv 获取环境变量
-func (f *Function) makeEnv() Environment {
-	return NewNestedEnvironment(f.env)
+// makeEnv 获取环境变量, 已解析的函数以数组保存变量, 函数体在调用者的执行状态t中执行
+func (f *Function) makeEnv(t *thread) Environment {
+	if f.body.layout.resolved {
+		frame := newFrame(f.body.layout, f.env)
+		frame.thread = t
+		return frame
+	}
+	return NewNestedEnvironment(&threadEnv{f.env, t})
+}
+
+// bind 设置第index个参数的值
+func (f *Function) bind(env Environment, index int, value interface{}) {
+	if frame, ok := env.(*Frame); ok {
+		// 参数依次占用帧中的前几个位置
+		frame.values[index] = value
+		return
+	}
+	f.parameters.EvalSub(env, index, value)
+}
+
+// invoke 在env中执行函数体并返回结果, 执行期间在t的调用栈中记录以site调用的函数
+func (f *Function) invoke(t *thread, site TreeNode, env Environment) interface{} {
+	t.enter(f, site)
+	result := f.body.Eval(env)
+	t.leave()
+	if r, ok := result.(returnJump); ok {
+		return r.value
+	}
+	return result
+}
+
+// Call 在env所属的执行状态中以实参调用函数, 参数个数不符时以node的位置报告ArityError
+func (f *Function) Call(env Environment, node TreeNode, args []Value) interface{} {
+	return f.run(currentThread(env), node, args)
+}
+
+// checkArity 检查实参个数
+func (f *Function) checkArity(node TreeNode, args []Value) {
+	if len(args) != f.parameters.Size() {
+		panic(NewArityError(node, f.parameters.Size(), len(args)))
+	}
+}
+
+// run 在执行状态t中以实参调用函数
+func (f *Function) run(t *thread, node TreeNode, args []Value) interface{} {
+	f.checkArity(node, args)
+	newEnv := f.makeEnv(t)
+	for i, v := range args {
+		f.bind(newEnv, i, v)
+	}
+	return f.invoke(t, node, newEnv)
+}
+
+// CallValue 在env所属的执行状态中调用脚本函数或Go函数, fn不是函数时抛出TypeError
+func CallValue(env Environment, node TreeNode, fn interface{}, args []Value) interface{} {
+	switch f := fn.(type) {
+	case *Function:
+		return f.Call(env, node, args)
+	case *NativeFunction:
+		return f.call(env, node, args)
+	}
+	panic(NewTypeError(node, "bad function"))
 }
 
 // String String方法
 func (f *Function) String() string {
-	return fmt.Sprintf("<fun: %v >", &f)
+	return fmt.Sprintf("<fun:%p>", f)
 }
@@ -13,6 +13,7 @@ type TreeNode interface {
 	ChildSize() int                           // 子节点个数
 	Children() *list.ArrayList                // 获取子节点
 	Location() string                         // 定位显示
+	Span() Span                               // 在源码中的区间
 	String() string                           // 实现String接口
 	Eval(environment Environment) interface{} // 获取节点计算值
 }
@@ -20,10 +21,14 @@ type TreeNode interface {
 // NewTreeNode 创建语法树节点
 func NewTreeNode(treeType interface{}, arg interface{}) TreeNode {
 	switch treeType.(type) {
+	case LeafNode:
+		return NewLeafNode(arg.(Token))
 	case PrimaryExpr:
 		return CreatePrimaryExpr(arg.(*list.ArrayList))
 	case NegativeExprNode:
 		return NewNegativeExprNode(arg.(*list.ArrayList))
+	case NotExprNode:
+		return NewNotExprNode(arg.(*list.ArrayList))
 	case BlockStatementNode:
 		return NewBlockStatementNode(arg.(*list.ArrayList))
 	case NumberNode:
@@ -32,6 +37,10 @@ func NewTreeNode(treeType interface{}, arg interface{}) TreeNode {
 		return NewVariableNode(arg.(Token))
 	case StringNode:
 		return NewStringNode(arg.(Token))
+	case BoolNode:
+		return NewBoolNode(arg.(Token))
+	case NilNode:
+		return NewNilNode(arg.(Token))
 	case BinaryExprNode:
 		return NewBinaryExprNode(arg.(*list.ArrayList))
 	case IfStatementNode:
@@ -46,6 +55,50 @@ func NewTreeNode(treeType interface{}, arg interface{}) TreeNode {
 		return NewDefStatementNode(arg.(*list.ArrayList))
 	case ArgumentsNode:
 		return NewArgumentsNode(arg.(*list.ArrayList))
+	case FunNode:
+		return NewFunNode(arg.(*list.ArrayList))
+	case ReturnStatementNode:
+		return NewReturnStatementNode(arg.(*list.ArrayList))
+	case BreakStatementNode:
+		return NewBreakStatementNode(arg.(*list.ArrayList))
+	case ContinueStatementNode:
+		return NewContinueStatementNode(arg.(*list.ArrayList))
+	case ArrayLiteralNode:
+		return NewArrayLiteralNode(arg.(*list.ArrayList))
+	case ArrayRefNode:
+		return NewArrayRefNode(arg.(*list.ArrayList))
+	case SliceNode:
+		return NewSliceNode(arg.(*list.ArrayList))
+	case MapLiteralNode:
+		return NewMapLiteralNode(arg.(*list.ArrayList))
+	case MapEntryNode:
+		return NewMapEntryNode(arg.(*list.ArrayList))
+	case DotNode:
+		return NewDotNode(arg.(*list.ArrayList))
+	case DelStatementNode:
+		return NewDelStatementNode(arg.(*list.ArrayList))
+	case ClassStatementNode:
+		return NewClassStatementNode(arg.(*list.ArrayList))
+	case ClassBodyNode:
+		return NewClassBodyNode(arg.(*list.ArrayList))
+	case DeclStatementNode:
+		return NewDeclStatementNode(arg.(*list.ArrayList))
+	case ForInStatementNode:
+		return NewForInStatementNode(arg.(*list.ArrayList))
+	case ForStatementNode:
+		return NewForStatementNode(arg.(*list.ArrayList))
+	case ForClauseNode:
+		return NewForClauseNode(arg.(*list.ArrayList))
+	case ThrowStatementNode:
+		return NewThrowStatementNode(arg.(*list.ArrayList))
+	case TryStatementNode:
+		return NewTryStatementNode(arg.(*list.ArrayList))
+	case CatchClauseNode:
+		return NewCatchClauseNode(arg.(*list.ArrayList))
+	case FinallyClauseNode:
+		return NewFinallyClauseNode(arg.(*list.ArrayList))
+	case SpawnNode:
+		return NewSpawnNode(arg.(*list.ArrayList))
 	}
 	return nil
 }
@@ -81,7 +134,16 @@ func (l LeafNode) Children() *list.ArrayList {
 
 // Location 定位显示
 func (l LeafNode) Location() string {
-	return fmt.Sprintf("at line %v", l.token.GetLineNumber())
+	pos := l.token.GetPosition()
+	return fmt.Sprintf("at line %v, column %v", pos.Line, pos.Column)
+}
+
+// Span 在源码中的区间
+func (l LeafNode) Span() Span {
+	if l.token == nil {
+		return Span{}
+	}
+	return l.token.GetSpan()
 }
 
 // String 实现String方法方便打印
@@ -89,8 +151,14 @@ func (l LeafNode) String() string {
 	return l.token.GetText()
 }
 
+// Eval 获取计算值
 func (l LeafNode) Eval(env Environment) interface{} {
-	panic(fmt.Sprintf("cannot eval: %v", l.String()))
+	panic(NewRuntimeError(l, "cannot eval: %v", l.String()))
+}
+
+// firstToken 获取第一个单词
+func (l LeafNode) firstToken() Token {
+	return l.token
 }
 
 // NumberNode 数值型叶子节点
@@ -110,8 +178,11 @@ func (n NumberNode) Eval(env Environment) interface{} {
 	return n.Value()
 }
 
-// Value 获取值
-func (n NumberNode) Value() int {
+// Value 获取值, 为int、float64或*big.Int
+func (n NumberNode) Value() interface{} {
+	if t, ok := n.token.(NumToken); ok {
+		return t.Value()
+	}
 	num, _ := n.token.GetNumber()
 	return num
 }
@@ -119,27 +190,87 @@ func (n NumberNode) Value() int {
 // VariableNode 变量叶子节点
 type VariableNode struct {
 	LeafNode
+	addr *address // 解析器分配的地址
 }
 
 // NewVariableNode 创建VariableNode对象
 func NewVariableNode(token Token) VariableNode {
-	return VariableNode{LeafNode: NewLeafNode(token)}
+	return VariableNode{LeafNode: NewLeafNode(token), addr: &address{}}
 }
 
-// Eval 获取计算值
+// Eval 获取计算值, 已解析的局部变量直接从帧中读取
 func (v VariableNode) Eval(env Environment) interface{} {
-	value := env.Get(v.Name())
-	if value == nil {
-		panic(fmt.Sprintf("undefined name: %v", v.Name()))
+	if e, ok := outerAt(env, v.addr.depth); ok {
+		switch v.addr.kind {
+		case addrLocal:
+			value := e.(*Frame).load(v.addr.slot)
+			if value == unset {
+				panic(NewNameError(v, v.Name()))
+			}
+			return value
+		case addrGlobal:
+			env = e
+		}
+	}
+	value, ok := env.Get(v.Name())
+	if !ok {
+		panic(NewNameError(v, v.Name()))
 	}
 	return value
 }
 
+// Assign 为变量赋值, 已解析的局部变量直接保存到帧中
+func (v VariableNode) Assign(env Environment, value interface{}) {
+	if v.addr.kind == addrDynamic && isConst(env, v.Name()) {
+		panic(NewTypeError(v, "cannot assign to constant: %v", v.Name()))
+	}
+	if e, ok := outerAt(env, v.addr.depth); ok {
+		switch v.addr.kind {
+		case addrLocal:
+			e.(*Frame).store(v.addr.slot, value)
+			return
+		case addrGlobal:
+			env = e
+		}
+	}
+	env.Put(v.Name(), value)
+}
+
 // Name 获取变量名
 func (v VariableNode) Name() string {
 	return v.token.GetText()
 }
 
+// BoolNode 布尔字面量叶子节点
+type BoolNode struct {
+	LeafNode
+}
+
+// NewBoolNode 创建BoolNode对象
+func NewBoolNode(token Token) BoolNode {
+	return BoolNode{LeafNode: NewLeafNode(token)}
+}
+
+// Eval 获取计算值
+func (b BoolNode) Eval(env Environment) interface{} {
+	return b.token.GetText() == "true"
+}
+
+// NilNode nil字面量叶子节点
+type NilNode struct {
+	LeafNode
+}
+
+// NewNilNode 创建NilNode对象
+func NewNilNode(token Token) NilNode {
+	return NilNode{LeafNode: NewLeafNode(token)}
+}
+
+// Eval 获取计算值
+func (n NilNode) Eval(env Environment) interface{} {
+	return nil
+}
+
 // StringNode
 type StringNode struct {
 	LeafNode
@@ -172,7 +303,18 @@ func NewBranchNode(list *list.ArrayList) BranchNode {
 
 // Eval 获取计算值
 func (b BranchNode) Eval(env Environment) interface{} {
-	panic(fmt.Sprintf("cannot eval: %v", b.String()))
+	panic(NewRuntimeError(b, "cannot eval: %v", b.String()))
+}
+
+// firstToken 获取第一个单词
+func (b BranchNode) firstToken() Token {
+	for i := 0; i < b.list.Size(); i++ {
+		node, _ := b.list.Get(i)
+		if t := firstToken(node.(TreeNode)); t != nil {
+			return t
+		}
+	}
+	return nil
 }
 
 // Child 获取树枝节点下指定的子节点
@@ -193,16 +335,29 @@ func (b BranchNode) Children() *list.ArrayList {
 
 // Location 定位显示
 func (b BranchNode) Location() string {
-	result := ""
+	for i := 0; i < b.list.Size(); i++ {
+		c, _ := b.list.Get(i)
+		if s := c.(TreeNode).Location(); s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// Span 在源码中的区间, 由第一个和最后一个有效的子节点计算
+func (b BranchNode) Span() Span {
+	var span Span
 	b.list.For(func(k int, v interface{}) {
-		c := v.(TreeNode)
-		s := c.Location()
-		if s != "" {
-			result = s
+		s := v.(TreeNode).Span()
+		if !s.IsValid() {
 			return
 		}
+		if !span.IsValid() {
+			span.Start = s.Start
+		}
+		span.End = s.End
 	})
-	return result
+	return span
 }
 
 // String 实现String接口
@@ -233,12 +388,15 @@ func NewNegativeExprNode(list *list.ArrayList) NegativeExprNode {
 
 // Eval 获取计算值
 func (n NegativeExprNode) Eval(env Environment) interface{} {
-	value := n.Operand().Eval(env)
-	switch value.(type) {
-	case int:
-		return -value.(int)
+	return n.Compute(n.Operand().Eval(env))
+}
+
+// Compute 计算操作数的相反数
+func (n NegativeExprNode) Compute(value interface{}) interface{} {
+	if v, ok := negate(value); ok {
+		return v
 	}
-	panic(fmt.Sprintf("bad type for -"))
+	panic(NewTypeError(n, "bad type for -"))
 }
 
 // Operand
@@ -252,6 +410,32 @@ func (n NegativeExprNode) String() string {
 	return fmt.Sprintf("-%v", n.Operand())
 }
 
+// NotExprNode 逻辑非表达式节点
+type NotExprNode struct {
+	BranchNode
+}
+
+// NewNotExprNode 创建NotExprNode对象
+func NewNotExprNode(list *list.ArrayList) NotExprNode {
+	return NotExprNode{NewBranchNode(list)}
+}
+
+// Eval 获取计算值
+func (n NotExprNode) Eval(env Environment) interface{} {
+	return !Truthy(n.Operand().Eval(env))
+}
+
+// Operand 操作数
+func (n NotExprNode) Operand() TreeNode {
+	node, _ := n.list.Get(0)
+	return node.(TreeNode)
+}
+
+// String
+func (n NotExprNode) String() string {
+	return fmt.Sprintf("!%v", n.Operand())
+}
+
 // BinaryExprNode 双目运算表达式节点
 type BinaryExprNode struct {
 	BranchNode
@@ -271,9 +455,21 @@ func (b BinaryExprNode) Eval(env Environment) interface{} {
 		right := b.Right().Eval(env)
 		return b.computeAssign(env, right)
 	}
+	// 短路求值
+	if op == "&&" {
+		return Truthy(b.Left().Eval(env)) && Truthy(b.Right().Eval(env))
+	}
+	if op == "||" {
+		return Truthy(b.Left().Eval(env)) || Truthy(b.Right().Eval(env))
+	}
 	left := b.Left().Eval(env)
 	right := b.Right().Eval(env)
-	return b.computeOp(left, op, right)
+	result := b.computeOp(left, op, right)
+	if s, ok := result.(string); ok {
+		// 拼接的字符串按长度计数分配
+		allocateString(env, b, len(s))
+	}
+	return result
 }
 
 // Left 获取子节点中的左子节点
@@ -303,72 +499,106 @@ func (b BinaryExprNode) computeAssign(env Environment, rightVal interface{}) int
 	left := b.Left()
 	switch left.(type) {
 	case VariableNode:
-		env.Put(left.(VariableNode).Name(), rightVal)
+		left.(VariableNode).Assign(env, rightVal)
 		return rightVal
+	case PrimaryExpr:
+		// a[i] = v, m.k = v, obj.field = v
+		p := left.(PrimaryExpr)
+		switch postfix := p.Postfix(0).(type) {
+		case ArrayRefNode:
+			return postfix.Assign(env, p.EvalSubExpr(env, 1), rightVal)
+		case DotNode:
+			return postfix.Assign(env, p.EvalSubExpr(env, 1), rightVal)
+		}
 	}
-	panic(fmt.Sprintf("bad assignment"))
+	panic(NewRuntimeError(b, "bad assignment"))
+}
+
+// Compute 以两个操作数的值计算该表达式, 用于"="、"&&"、"||"以外的操作符
+func (b BinaryExprNode) Compute(left interface{}, right interface{}) interface{} {
+	return b.computeOp(left, b.Operator(), right)
 }
 
 // computeOp 表达式计算
 func (b BinaryExprNode) computeOp(left interface{}, op string, right interface{}) interface{} {
-	nl, lok := left.(int)
-	nr, rok := right.(int)
-	if lok && rok {
-		return computeNumber(nl, op, nr)
+	if op == "in" {
+		return b.contains(left, right)
 	}
-	if op == "+" {
-		return fmt.Sprintf("%s%s", left, right)
+	if isNumber(left) && isNumber(right) {
+		return b.computeNumber(left, op, right)
 	}
-	if op == "==" {
-		if left == nil {
-			if right == nil {
-				return TRUE
-			} else {
-				return FALSE
-			}
+	sl, lok := left.(string)
+	sr, rok := right.(string)
+	if lok && rok {
+		if v, ok := b.computeString(sl, op, sr); ok {
+			return v
 		}
-		if left == right {
-			return TRUE
-		} else {
-			return FALSE
+	}
+	switch op {
+	case "+":
+		if lok || rok {
+			return ToString(left) + ToString(right)
+		}
+	case "==":
+		return left == right
+	case "!=":
+		return left != right
+	}
+	panic(NewTypeError(b.operatorNode(), "bad type for %v", op))
+}
+
+// operatorNode 获取操作符节点
+func (b BinaryExprNode) operatorNode() TreeNode {
+	node, _ := b.list.Get(1)
+	return node.(TreeNode)
+}
+
+// contains in操作, 判断映射是否包含键、数组是否包含元素或字符串是否包含子串
+func (b BinaryExprNode) contains(left interface{}, right interface{}) bool {
+	switch r := right.(type) {
+	case *Map:
+		return isMapKey(left) && r.Has(left)
+	case *list.ArrayList:
+		found := false
+		r.For(func(k int, v interface{}) {
+			found = found || b.equals(left, v)
+		})
+		return found
+	case string:
+		if l, ok := left.(string); ok {
+			return strings.Contains(r, l)
 		}
 	}
-	panic(fmt.Sprintf("bad type"))
+	panic(NewTypeError(b.operatorNode(), "bad type for in"))
 }
 
-// computeNumber 整型计算
-func computeNumber(left int, op string, right int) interface{} {
+// equals 判断两个值是否相等, 数值按大小比较
+func (b BinaryExprNode) equals(left interface{}, right interface{}) bool {
+	if isNumber(left) && isNumber(right) {
+		return b.computeNumber(left, "==", right).(bool)
+	}
+	return left == right
+}
+
+// computeString 字符串的拼接与比较, 不支持的操作返回false
+func (b BinaryExprNode) computeString(left string, op string, right string) (interface{}, bool) {
 	switch op {
 	case "+":
-		return left + right
-	case "-":
-		return left - right
-	case "*":
-		return left * right
-	case "/":
-		return left / right
-	case "%":
-		return left % right
+		return left + right, true
 	case "==":
-		if left == right {
-			return TRUE
-		} else {
-			return FALSE
-		}
+		return left == right, true
+	case "!=":
+		return left != right, true
 	case ">":
-		if left > right {
-			return TRUE
-		} else {
-			return FALSE
-		}
+		return left > right, true
 	case "<":
-		if left < right {
-			return TRUE
-		} else {
-			return FALSE
-		}
+		return left < right, true
+	case ">=":
+		return left >= right, true
+	case "<=":
+		return left <= right, true
 	}
-	panic(fmt.Sprintf("bad operator"))
+	return nil, false
 }
 
 // PrimaryExpr
@@ -381,13 +611,13 @@ func NewPrimaryExpr(list *list.ArrayList) PrimaryExpr {
 	return PrimaryExpr{NewBranchNode(list)}
 }
 
-// CreatePrimaryExpr
+// CreatePrimaryExpr 只有一个子节点时直接返回该子节点
 func CreatePrimaryExpr(list *list.ArrayList) TreeNode {
 	if list.Size() == 1 {
 		node, _ := list.Get(0)
 		return node.(TreeNode)
 	} else {
-		return NewBranchNode(list)
+		return NewPrimaryExpr(list)
 	}
 }
 
@@ -422,31 +652,58 @@ func (p PrimaryExpr) Eval(env Environment) interface{} {
 func (p PrimaryExpr) EvalSubExpr(env Environment, nest int) interface{} {
 	if p.HasPostfix(nest) {
 		t := p.EvalSubExpr(env, nest+1)
-		return p.Postfix(nest).EvalSub(env, t)
+		postfix := p.Postfix(nest)
+		if postfix.ChildSize() == 0 {
+			defer p.locateError()
+		}
+		if args, ok := postfix.(ArgumentsNode); ok {
+			// 以整个调用表达式的位置作为调用栈中的调用位置
+			return args.call(env, t, p)
+		}
+		return postfix.EvalSub(env, t)
 	}
 	return p.Operand().Eval(env)
 }
 
+// locateError 没有位置的运行时错误(如无参数调用时的错误)以该表达式的位置作为出错位置
+func (p PrimaryExpr) locateError() {
+	if r := recover(); r != nil {
+		if e, ok := r.(interface{ locate(span Span, token Token) }); ok {
+			e.locate(p.Span(), firstToken(p))
+		}
+		panic(r)
+	}
+}
+
 // BlockStatementNode
 type BlockStatementNode struct {
 	BranchNode
+	layout *layout // 代码块或函数体帧中的变量, 由解析器填写
 }
 
 // NewBlockStatementNode
 func NewBlockStatementNode(list *list.ArrayList) BlockStatementNode {
-	return BlockStatementNode{NewBranchNode(list)}
+	return BlockStatementNode{NewBranchNode(list), &layout{}}
 }
 
 // Eval 获取计算值
 func (b BlockStatementNode) Eval(env Environment) interface{} {
+	if b.layout.resolved && !b.layout.function {
+		// 代码块中定义的变量保存在新的帧中, 每次执行都重新创建
+		env = newFrame(b.layout, env)
+	}
 	var result interface{}
-	result = 0
-	b.Children().For(func(k int, v interface{}) {
-		_, ok := v.(NullStatementNode)
-		if !ok {
-			result = v.(TreeNode).Eval(env)
+	for i := 0; i < b.ChildSize(); i++ {
+		v, _ := b.Child(i)
+		if _, ok := v.(NullStatementNode); ok {
+			continue
 		}
-	})
+		result = v.Eval(env)
+		// 遇到return、break、continue时停止执行, 交由外层处理
+		if isJump(result) {
+			return result
+		}
+	}
 	return result
 }
 
@@ -462,14 +719,12 @@ func NewIfStatementNode(list *list.ArrayList) IfStatementNode {
 
 // Eval 获取计算值
 func (i IfStatementNode) Eval(env Environment) interface{} {
-	c := i.Condition().Eval(env)
-	cv, cok := c.(int)
-	if cok && cv != FALSE {
+	if Truthy(i.Condition().Eval(env)) {
 		return i.ThenBlock().Eval(env)
 	}
 	b := i.ElseBlock()
 	if b == nil {
-		return 0
+		return nil
 	}
 	return b.Eval(env)
 }
@@ -521,15 +776,23 @@ func NewWhileStatementNode(list *list.ArrayList) WhileStatementNode {
 
 // Eval 获取计算值
 func (w WhileStatementNode) Eval(env Environment) interface{} {
+	t := currentThread(env)
 	var result interface{}
-	result = 0
 	for {
-		c := w.Condition().Eval(env)
-		cv, cok := c.(int)
-		if cok && cv == FALSE {
+		t.step(w)
+		if !Truthy(w.Condition().Eval(env)) {
 			return result
 		}
-		result = w.Body().Eval(env)
+		r := w.Body().Eval(env)
+		switch r.(type) {
+		case breakJump:
+			return result
+		case continueJump:
+			continue
+		case returnJump:
+			return r
+		}
+		result = r
 	}
 }
 
@@ -638,53 +901,226 @@ func (d DefStatementNode) String() string {
 
 // Eval 获取计算值
 func (d DefStatementNode) Eval(env Environment) interface{} {
-	env.PutNew(d.Name(), NewFunction(d.Parameters(), d.Body(), env))
+	allocate(env, d)
+	f := NewFunction(d.Parameters(), d.Body(), env)
+	f.name = d.Name()
+	env.PutNew(d.Name(), f)
 	return d.Name()
 }
 
-// Postfix
-type Postfix struct {
+// FunNode 匿名函数(闭包)节点
+type FunNode struct {
 	BranchNode
-	EvalSub func(environment Environment, value interface{}) interface{}
 }
 
-// NewPostfix
-func NewPostfix(list *list.ArrayList) Postfix {
-	return Postfix{
-		NewBranchNode(list),
-		nil,
+// NewFunNode 创建FunNode
+func NewFunNode(list *list.ArrayList) FunNode {
+	return FunNode{NewBranchNode(list)}
+}
+
+// Parameters 参数信息
+func (f FunNode) Parameters() ParameterListNode {
+	node, err := f.Child(0)
+	if err != nil {
+		panic(err)
 	}
+	return node.(ParameterListNode)
+}
+
+// Body 函数体信息
+func (f FunNode) Body() BlockStatementNode {
+	node, err := f.Child(1)
+	if err != nil {
+		panic(err)
+	}
+	return node.(BlockStatementNode)
+}
+
+// String 实现String
+func (f FunNode) String() string {
+	return fmt.Sprintf("(fun %v %v)", f.Parameters(), f.Body())
+}
+
+// Eval 获取计算值, 创建捕获当前环境的函数对象
+func (f FunNode) Eval(env Environment) interface{} {
+	allocate(env, f)
+	return NewFunction(f.Parameters(), f.Body(), env)
+}
+
+// Postfix 后缀, 对前面表达式的计算值进行计算
+type Postfix interface {
+	TreeNode
+	EvalSub(env Environment, value interface{}) interface{} // 以前面表达式的计算值计算
 }
 
 // ArgumentsNode 参数
 type ArgumentsNode struct {
-	Postfix
+	BranchNode
 }
 
 // NewArgumentsNode 创建Arguments对象
 func NewArgumentsNode(list *list.ArrayList) ArgumentsNode {
-	node := ArgumentsNode{NewPostfix(list)}
-	node.EvalSub = func(env Environment, value interface{}) interface{} {
-		fv, fok := value.(Function)
-		if !fok {
-			panic(fmt.Sprintf("bad function %v", node))
-		}
-		params := fv.parameters
-		if node.Size() != params.Size() {
-			panic(fmt.Sprintf("bad number of arguments %v", node))
-		}
-		newEnv := fv.makeEnv()
-		num := 0
-		node.Children().For(func(k int, v interface{}) {
-			params.EvalSub(newEnv, num, v.(TreeNode).Eval(env))
-			num++
+	return ArgumentsNode{NewBranchNode(list)}
+}
+
+// EvalSub 以实参调用函数, 函数体在以定义时环境为外层的新环境中执行
+func (a ArgumentsNode) EvalSub(env Environment, value interface{}) interface{} {
+	return a.call(env, value, a)
+}
+
+// call 以实参调用函数, site为调用表达式
+func (a ArgumentsNode) call(env Environment, value interface{}, site TreeNode) interface{} {
+	if nf, ok := value.(*NativeFunction); ok {
+		args := make([]Value, 0, a.Size())
+		a.Children().For(func(k int, v interface{}) {
+			args = append(args, v.(TreeNode).Eval(env))
 		})
-		return fv.Body().Eval(env)
+		return nf.call(env, a, args)
 	}
-	return node
+	fv, fok := value.(*Function)
+	if !fok {
+		panic(NewTypeError(a, "bad function"))
+	}
+	params := fv.parameters
+	if a.Size() != params.Size() {
+		panic(NewArityError(a, params.Size(), a.Size()))
+	}
+	t := currentThread(env)
+	newEnv := fv.makeEnv(t)
+	a.Children().For(func(k int, v interface{}) {
+		fv.bind(newEnv, k, v.(TreeNode).Eval(env))
+	})
+	return fv.invoke(t, site, newEnv)
 }
 
 // Size 数量
 func (a ArgumentsNode) Size() int {
 	return a.ChildSize()
 }
+
+// ErrorNode 错误恢复时代替出错语句的节点
+type ErrorNode struct {
+	BranchNode
+	err *SyntaxError
+}
+
+// NewErrorNode 创建ErrorNode
+func NewErrorNode(err *SyntaxError) ErrorNode {
+	return ErrorNode{NewBranchNode(list.New(0)), err}
+}
+
+// Err 获取语法错误
+func (e ErrorNode) Err() *SyntaxError {
+	return e.err
+}
+
+// Span 在源码中的区间
+func (e ErrorNode) Span() Span {
+	return Span{e.err.Position, e.err.End}
+}
+
+// String 实现String接口
+func (e ErrorNode) String() string {
+	return fmt.Sprintf("(error %q)", e.err.Msg)
+}
+
+// Eval 获取计算值, 执行时抛出语法错误
+func (e ErrorNode) Eval(env Environment) interface{} {
+	panic(e.err)
+}
+
+// returnJump return语句的执行结果, 由函数调用处取出返回值
+type returnJump struct {
+	value interface{}
+}
+
+// breakJump break语句的执行结果, 由循环处理
+type breakJump struct{}
+
+// continueJump continue语句的执行结果, 由循环处理
+type continueJump struct{}
+
+// isJump 是否为return、break、continue语句的执行结果
+func isJump(v interface{}) bool {
+	switch v.(type) {
+	case returnJump, breakJump, continueJump:
+		return true
+	}
+	return false
+}
+
+// ReturnStatementNode return语句, 第一个子节点为return关键字
+type ReturnStatementNode struct {
+	BranchNode
+}
+
+// NewReturnStatementNode 创建ReturnStatementNode
+func NewReturnStatementNode(list *list.ArrayList) ReturnStatementNode {
+	return ReturnStatementNode{NewBranchNode(list)}
+}
+
+// Value 返回值表达式, 没有时为nil
+func (r ReturnStatementNode) Value() TreeNode {
+	if r.ChildSize() < 2 {
+		return nil
+	}
+	node, _ := r.Child(1)
+	return node
+}
+
+// String 实现String接口
+func (r ReturnStatementNode) String() string {
+	if v := r.Value(); v != nil {
+		return fmt.Sprintf("(return %v)", v)
+	}
+	return "(return)"
+}
+
+// Eval 获取计算值
+func (r ReturnStatementNode) Eval(env Environment) interface{} {
+	var value interface{}
+	if v := r.Value(); v != nil {
+		value = v.Eval(env)
+	}
+	return returnJump{value}
+}
+
+// BreakStatementNode break语句
+type BreakStatementNode struct {
+	BranchNode
+}
+
+// NewBreakStatementNode 创建BreakStatementNode
+func NewBreakStatementNode(list *list.ArrayList) BreakStatementNode {
+	return BreakStatementNode{NewBranchNode(list)}
+}
+
+// String 实现String接口
+func (b BreakStatementNode) String() string {
+	return "(break)"
+}
+
+// Eval 获取计算值
+func (b BreakStatementNode) Eval(env Environment) interface{} {
+	return breakJump{}
+}
+
+// ContinueStatementNode continue语句
+type ContinueStatementNode struct {
+	BranchNode
+}
+
+// NewContinueStatementNode 创建ContinueStatementNode
+func NewContinueStatementNode(list *list.ArrayList) ContinueStatementNode {
+	return ContinueStatementNode{NewBranchNode(list)}
+}
+
+// String 实现String接口
+func (c ContinueStatementNode) String() string {
+	return "(continue)"
+}
+
+// Eval 获取计算值
+func (c ContinueStatementNode) Eval(env Environment) interface{} {
+	return continueJump{}
+}
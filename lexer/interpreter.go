@@ -0,0 +1,72 @@
+package lexer
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+)
+
+// Config 解释器的配置
+type Config struct {
+	Out    io.Writer    // 输出函数写入的位置, 为nil时丢弃输出
+	Limits Limits       // 每次执行的限制
+	Grants []Capability // 授予脚本的能力, 未授予时脚本无法访问文件、环境变量等
+}
+
+// Interpreter 解释器实例, 拥有自己的全局环境、内置函数、执行限制及输出,
+// 不同实例之间不共享任何变量; spawn创建的任务属于解释器, 在多次执行之间继续运行, 由Close取消
+type Interpreter struct {
+	builtins BasicEnvironment
+	globals  *NestedEnvironment
+	limits   Limits
+	tasks    *taskSet
+}
+
+// NewInterpreter 创建解释器, 内置函数输出到config.Out, 并注册config.Grants授予的内置函数
+func NewInterpreter(config Config) *Interpreter {
+	out := config.Out
+	if out == nil {
+		out = ioutil.Discard
+	}
+	builtins := NewBuiltinEnv(out)
+	for _, c := range config.Grants {
+		c.Install(builtins)
+	}
+	return &Interpreter{
+		builtins: builtins,
+		globals:  NewNestedEnvironment(builtins),
+		limits:   config.Limits,
+		tasks:    newTaskSet(context.Background()),
+	}
+}
+
+// Globals 全局环境, 宿主可以在其中注册函数和值
+func (i *Interpreter) Globals() *NestedEnvironment {
+	return i.globals
+}
+
+// Get 获取全局变量或内置函数
+func (i *Interpreter) Get(name string) (Value, bool) {
+	return i.globals.Get(name)
+}
+
+// Exec 解析并执行源码, 语法错误以*SyntaxError返回
+func (i *Interpreter) Exec(ctx context.Context, file string, reader io.Reader) (Value, error) {
+	nodes, err := Parse(file, reader)
+	if err != nil {
+		return nil, err
+	}
+	return i.Eval(ctx, nodes)
+}
+
+// Eval 在全局环境中执行语句, 受解释器的执行限制; 解析变量时会修改语法树, 因此语法树不能同时在多个解释器中执行.
+// 执行结束时不取消spawn创建的任务, 之后的执行中仍可以wait这些任务
+func (i *Interpreter) Eval(ctx context.Context, nodes []TreeNode) (Value, error) {
+	return eval(ctx, nodes, i.globals, i.limits, i.tasks)
+}
+
+// Close 取消spawn创建且尚未结束的任务并等待其退出, 未被wait等待的任务出错时返回该任务的错误;
+// 之后创建的任务立即被取消
+func (i *Interpreter) Close() error {
+	return i.tasks.finish()
+}
@@ -2,15 +2,16 @@ package lexer
 
 import (
 	"bufio"
-	"errors"
 	"fmt"
+	"math/big"
 	"regexp"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 // regexPat 正则表达式,使用命名分组
-const regexPat = `\s*((?P<notes>//.*)|(?P<number>[0-9]+)|(?P<stringVal>"(\\"|\\\\|\\n|[^"])*")|(?P<string>[A-Z_a-z][A-Z_a-z0-9]*|==|<=|>=|&&|\|\||[[:punct:]]))?` // 匹配的正则表达式
+const regexPat = `\s*((?P<notes>//.*)|(?P<number>0[xX][0-9a-fA-F_]*|0[oO][0-7_]*|0[bB][01_]*|[0-9][0-9_]*(?:\.[0-9][0-9_]*)?(?:[eE][+-]?[0-9]+)?)|(?P<stringVal>"(\\"|\\\\|\\n|[^"])*")|(?P<string>[A-Z_a-z][A-Z_a-z0-9]*|==|!=|<=|>=|&&|\|\||[[:punct:]]))?` // 匹配的正则表达式
 
 // Lexer 词法分析器
 type Lexer struct {
@@ -19,17 +20,41 @@ type Lexer struct {
 	hasMore bool           // 是否还有为解析单词
 	reader  *bufio.Scanner // 内容读取器
 	lineNo  int            // 行号
+	offset  int            // 下一行起始处的字节偏移量
+	eolSize int            // 刚读取的一行的行结束符的字节数, "\r\n"为2, 没有行结束符时为0
+	end     Position       // 已读取内容的结尾位置
+	file    string         // 文件名
+	recover bool           // 是否开启错误恢复
+	errors  []*SyntaxError // 错误恢复时记录的语法错误
 }
 
 // NewLexer 创建Lexer对象
 func NewLexer(reader *bufio.Scanner) *Lexer {
+	return NewFileLexer("", reader)
+}
+
+// NewFileLexer 创建Lexer对象, 单词的位置中记录文件名; reader的分割函数会被替换为按行分割, 因此不能已经开始读取
+func NewFileLexer(file string, reader *bufio.Scanner) *Lexer {
 	pattern, _ := regexp.Compile(regexPat)
-	return &Lexer{
+	l := &Lexer{
 		pattern: pattern,
 		queue:   make([]Token, 0),
 		hasMore: true,
 		reader:  reader,
+		file:    file,
+		end:     Position{File: file},
+	}
+	reader.Split(l.scanLine)
+	return l
+}
+
+// scanLine 与bufio.ScanLines相同, 同时记录行结束符的字节数, 以便计算下一行的偏移量
+func (l *Lexer) scanLine(data []byte, atEOF bool) (int, []byte, error) {
+	advance, token, err := bufio.ScanLines(data, atEOF)
+	if token != nil {
+		l.eolSize = advance - len(token)
 	}
+	return advance, token, err
 }
 
 // Read 从源代码源头逐一获取单词
@@ -47,6 +72,33 @@ func (l *Lexer) Read() (Token, error) {
 	}
 }
 
+// SetRecovery 设置是否开启错误恢复, 开启后解析器记录语法错误并跳过至下一个语句边界继续解析
+func (l *Lexer) SetRecovery(on bool) {
+	l.recover = on
+}
+
+// Errors 获取错误恢复时记录的语法错误
+func (l *Lexer) Errors() []*SyntaxError {
+	return l.errors
+}
+
+// locate 结尾处的错误以最后一行的结尾作为位置
+func (l *Lexer) locate(err *SyntaxError) *SyntaxError {
+	if err.Line <= 0 {
+		err.Position, err.End = l.end, l.end
+	}
+	return err
+}
+
+// addError 记录语法错误, 忽略与上一个错误位置相同的错误
+func (l *Lexer) addError(err *SyntaxError) {
+	l.locate(err)
+	if n := len(l.errors); n > 0 && l.errors[n-1].Position == err.Position {
+		return
+	}
+	l.errors = append(l.errors, err)
+}
+
 // Peek 读取Read读取的单词n位后的单词
 func (l *Lexer) Peek(n int) (Token, error) {
 	fill, err := l.fillQueue(n)
@@ -90,47 +142,102 @@ func (l *Lexer) readLine() error {
 		l.hasMore = false
 		return nil
 	}
+	lineOffset := l.offset
+	// 按实际的行结束符计算偏移量, "\r\n"中的"\r"不包含在line中
+	l.offset += len(line) + l.eolSize
+	var badToken error
 	pos := 0
 	endPos := len(line)
-	matcherLine := line
-	for {
-		matcherLine := matcherLine[pos:]
-		if pos >= endPos {
-			break
-		}
-		loc := l.pattern.FindIndex([]byte(matcherLine))
-		// 起始匹配
-		if loc[0] == 0 {
-			l.addToken(l.lineNo, matcherLine)
+	for pos < endPos {
+		loc := l.pattern.FindStringSubmatchIndex(line[pos:])
+		// 起始匹配, 且必须读取到内容, 否则为无法识别的字符
+		if loc[0] == 0 && loc[1] > 0 {
+			if err := l.addToken(line[pos:], loc, l.position(lineOffset, pos)); err != nil && badToken == nil {
+				badToken = err
+			}
 			pos += loc[1]
 		} else {
-			return errors.New(fmt.Sprintf("bad token at line %d", l.lineNo))
+			// 跳过无法识别的字符并继续读取该行, 只报告第一个错误
+			_, size := utf8.DecodeRuneInString(line[pos:])
+			if badToken == nil {
+				start := l.position(lineOffset, pos)
+				badToken = &SyntaxError{Position: start, End: start.shift(size), Msg: "bad token"}
+			}
+			pos += size
 		}
 	}
-	l.queue = append(l.queue, NewIdToken(l.lineNo, EOL))
-	return nil
+	l.end = l.position(lineOffset, endPos)
+	l.queue = append(l.queue, NewIdToken(Span{l.end, l.end}, EOL))
+	return badToken
 }
 
-// addToken 创建并保存Token对象
-func (l *Lexer) addToken(lineNo int, lineStr string) {
-	// 命名分组
-	match := l.pattern.FindStringSubmatch(lineStr)
-	// groupNames := l.pattern.SubexpNames()
-	m := match[1]
+// position 当前行中第column个字节(从0开始)处的位置
+func (l *Lexer) position(lineOffset, column int) Position {
+	return Position{File: l.file, Line: l.lineNo, Column: column + 1, Offset: lineOffset + column}
+}
+
+// addToken 创建并保存Token对象, loc为命名分组的匹配位置, base为lineStr起始处的位置
+func (l *Lexer) addToken(lineStr string, loc []int, base Position) error {
+	// 命名分组: 1 单词, 2 注释, 3 整型, 4 字符串
+	group := func(n int) string {
+		if loc[2*n] < 0 {
+			return ""
+		}
+		return lineStr[loc[2*n]:loc[2*n+1]]
+	}
+	m := group(1)
 	if m != "" {
-		if match[2] == "" {
+		if group(2) == "" {
+			span := Span{Start: base.shift(loc[2]), End: base.shift(loc[3])}
 			var token Token
-			if match[3] != "" {
-				value, _ := strconv.Atoi(m)
-				token = NewNumToken(lineNo, value)
-			} else if match[4] != "" {
-				token = NewStrToken(lineNo, toStringLiteral(m))
+			if group(3) != "" {
+				value, ok := parseNumber(m)
+				if !ok {
+					// 仍保存该单词, 以免错误恢复时在下一个单词处再报告语法错误
+					l.queue = append(l.queue, NewNumToken(span, m, 0))
+					return &SyntaxError{Position: span.Start, End: span.End, Msg: fmt.Sprintf("bad number literal: %v", m)}
+				}
+				token = NewNumToken(span, m, value)
+			} else if group(4) != "" {
+				token = NewStrToken(span, toStringLiteral(m))
 			} else {
-				token = NewIdToken(lineNo, m)
+				token = NewIdToken(span, m)
 			}
 			l.queue = append(l.queue, token)
 		}
 	}
+	return nil
+}
+
+// parseNumber 解析数值字面量, 支持十六进制、八进制、二进制、下划线分隔以及浮点数,
+// 超出int范围的整数以*big.Int表示
+func parseNumber(text string) (interface{}, bool) {
+	if strings.HasSuffix(text, "_") || strings.Contains(text, "__") {
+		return nil, false
+	}
+	digits := strings.Replace(text, "_", "", -1)
+	base := 10
+	if len(digits) > 1 && digits[0] == '0' {
+		switch digits[1] {
+		case 'x', 'X':
+			base = 16
+		case 'o', 'O':
+			base = 8
+		case 'b', 'B':
+			base = 2
+		}
+	}
+	if base != 10 {
+		digits = digits[2:]
+	} else if strings.ContainsAny(digits, ".eE") {
+		f, err := strconv.ParseFloat(digits, 64)
+		return f, err == nil
+	}
+	if n, err := strconv.ParseInt(digits, base, strconv.IntSize); err == nil {
+		return int(n), true
+	}
+	n, ok := new(big.Int).SetString(digits, base)
+	return n, ok
 }
 
 // toStringLiteral 字符串类型的Token转换字符格式
@@ -0,0 +1,62 @@
+package lexer
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestBadNumberLiteral(t *testing.T) {
+	for _, src := range []string{"x = 1__0", "x = 0x", "x = 0b_", "x = 1_ + 2", "f(0o, 1)"} {
+		_, err := ParseWithRecovery("test.ssl", strings.NewReader(src+"\ny = 2\n"))
+		list, ok := err.(ErrorList)
+		if !ok || len(list) != 1 || !strings.Contains(list[0].Error(), "bad number literal") {
+			t.Errorf("%q: got %v, want one bad number literal error", src, err)
+		}
+	}
+}
+
+func TestNumberLiteral(t *testing.T) {
+	tests := []struct {
+		src  string
+		want interface{}
+	}{
+		{"0x1F", 31},
+		{"0o17", 15},
+		{"0b101", 5},
+		{"1_000", 1000},
+		{"2.5e1", 25.0},
+	}
+	for _, tt := range tests {
+		got, ok := parseNumber(tt.src)
+		if !ok || got != tt.want {
+			t.Errorf("%q: got %v %v, want %v", tt.src, got, ok, tt.want)
+		}
+	}
+}
+
+func TestSpans(t *testing.T) {
+	for _, eol := range []string{"\n", "\r\n"} {
+		src := strings.Join([]string{"a = 1", "  bb = \"x\" // c", "", "ccc(2)"}, eol)
+		l := NewLexer(bufio.NewScanner(strings.NewReader(src)))
+		var texts []string
+		for {
+			token, err := l.Read()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if token == EOF {
+				break
+			}
+			if token.GetText() == EOL {
+				continue
+			}
+			span := token.GetSpan()
+			texts = append(texts, src[span.Start.Offset:span.End.Offset])
+		}
+		want := "a|=|1|bb|=|\"x\"|ccc|(|2|)"
+		if got := strings.Join(texts, "|"); got != want {
+			t.Errorf("%q: got %v, want %v", eol, got, want)
+		}
+	}
+}
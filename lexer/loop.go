@@ -0,0 +1,298 @@
+package lexer
+
+import (
+	"fmt"
+	"simple-script-language/utils/list"
+)
+
+// Iterator 迭代器, 没有更多元素时Next返回false
+type Iterator interface {
+	Next() (Value, bool)
+}
+
+// Iterable 可迭代的值, 实现该接口的Go值可以在for-in循环中使用
+type Iterable interface {
+	Iterator() Iterator
+}
+
+// arrayIterator 数组的迭代器, 每次都按当前长度判断是否结束
+type arrayIterator struct {
+	array *list.ArrayList
+	index int
+}
+
+// Next 下一个元素
+func (a *arrayIterator) Next() (Value, bool) {
+	if a.index >= a.array.Size() {
+		return nil, false
+	}
+	item, _ := a.array.Get(a.index)
+	a.index++
+	return item, true
+}
+
+// sliceIterator 依次返回values中的值
+type sliceIterator struct {
+	values []Value
+	index  int
+}
+
+// Next 下一个值
+func (s *sliceIterator) Next() (Value, bool) {
+	if s.index >= len(s.values) {
+		return nil, false
+	}
+	s.index++
+	return s.values[s.index-1], true
+}
+
+// objectIterator 以对象的hasNext、next方法实现的迭代器
+type objectIterator struct {
+	env     Environment
+	node    TreeNode
+	hasNext interface{}
+	next    interface{}
+}
+
+// Next 下一个值
+func (o *objectIterator) Next() (Value, bool) {
+	if !Truthy(CallValue(o.env, o.node, o.hasNext, nil)) {
+		return nil, false
+	}
+	return CallValue(o.env, o.node, o.next, nil), true
+}
+
+// Iterate 获取value的迭代器: 数组依次返回元素, 映射依次返回键, 字符串依次返回字符, 通道依次接收值直到关闭,
+// 实现Iterable或Iterator的Go值使用自己的迭代器, 对象以iterator方法返回的对象或自身的hasNext、next方法迭代,
+// 这些方法在env所属的执行状态中调用
+func Iterate(env Environment, node TreeNode, value interface{}) Iterator {
+	switch v := value.(type) {
+	case *list.ArrayList:
+		return &arrayIterator{array: v}
+	case *Map:
+		return &sliceIterator{values: v.Keys()}
+	case string:
+		runes := []rune(v)
+		values := make([]Value, len(runes))
+		for i, r := range runes {
+			values[i] = string(r)
+		}
+		return &sliceIterator{values: values}
+	case *Channel:
+		return &channelIterator{v, currentThread(env), node}
+	case Iterable:
+		return v.Iterator()
+	case Iterator:
+		return v
+	case *GoObject:
+		switch g := v.Interface().(type) {
+		case Iterable:
+			return g.Iterator()
+		case Iterator:
+			return g
+		}
+	case *Object:
+		if fn, ok := v.Read("iterator"); ok {
+			it := CallValue(env, node, fn, nil)
+			if obj, ok := it.(*Object); ok {
+				return objectIteratorOf(env, node, obj, it)
+			}
+			return Iterate(env, node, it)
+		}
+		return objectIteratorOf(env, node, v, v)
+	}
+	panic(NewTypeError(node, "not iterable: %v", TypeName(value)))
+}
+
+// objectIteratorOf 以对象的hasNext、next方法创建迭代器
+func objectIteratorOf(env Environment, node TreeNode, obj *Object, value interface{}) Iterator {
+	hasNext, ok1 := obj.Read("hasNext")
+	next, ok2 := obj.Read("next")
+	if !ok1 || !ok2 {
+		panic(NewTypeError(node, "not iterable: %v", TypeName(value)))
+	}
+	return &objectIterator{env: env, node: node, hasNext: hasNext, next: next}
+}
+
+// loopResult 处理循环体的执行结果, 返回是否结束循环; 循环的计算值为最后一次执行循环体的值
+func loopResult(r interface{}, result *interface{}) bool {
+	switch r.(type) {
+	case breakJump:
+		return true
+	case continueJump:
+		return false
+	case returnJump:
+		*result = r
+		return true
+	}
+	*result = r
+	return false
+}
+
+// ForInStatementNode for-in循环, 如for x in a { ... }
+type ForInStatementNode struct {
+	BranchNode
+	layout *layout // 循环变量所在帧的变量, 由解析器填写
+}
+
+// NewForInStatementNode 创建ForInStatementNode
+func NewForInStatementNode(list *list.ArrayList) ForInStatementNode {
+	return ForInStatementNode{NewBranchNode(list), &layout{}}
+}
+
+// Name 循环变量名
+func (f ForInStatementNode) Name() string {
+	n, _ := f.Child(0)
+	return n.(LeafNode).token.GetText()
+}
+
+// Iterable 被迭代的表达式
+func (f ForInStatementNode) Iterable() TreeNode {
+	n, _ := f.Child(1)
+	return n
+}
+
+// Body 循环体
+func (f ForInStatementNode) Body() TreeNode {
+	n, _ := f.Child(2)
+	return n
+}
+
+// String 实现String接口
+func (f ForInStatementNode) String() string {
+	return fmt.Sprintf("(for %v in %v %v)", f.Name(), f.Iterable(), f.Body())
+}
+
+// Eval 获取计算值, 每次迭代都在新的环境中定义循环变量, 循环变量只在循环体中可见
+func (f ForInStatementNode) Eval(env Environment) interface{} {
+	it := Iterate(env, f.Iterable(), f.Iterable().Eval(env))
+	t := currentThread(env)
+	var result interface{}
+	for {
+		t.step(f)
+		value, ok := it.Next()
+		if !ok {
+			return result
+		}
+		var loopEnv Environment
+		if f.layout.resolved {
+			frame := newFrame(f.layout, env)
+			frame.values[0] = value
+			loopEnv = frame
+		} else {
+			nested := NewNestedEnvironment(env)
+			nested.PutNew(f.Name(), value)
+			loopEnv = nested
+		}
+		if loopResult(f.Body().Eval(loopEnv), &result) {
+			return result
+		}
+	}
+}
+
+// ForClauseNode C风格for循环中可以省略的初始化、条件或更新部分
+type ForClauseNode struct {
+	BranchNode
+}
+
+// NewForClauseNode 创建ForClauseNode
+func NewForClauseNode(list *list.ArrayList) ForClauseNode {
+	return ForClauseNode{NewBranchNode(list)}
+}
+
+// Expr 获取表达式, 省略时返回nil
+func (f ForClauseNode) Expr() TreeNode {
+	if f.ChildSize() == 0 {
+		return nil
+	}
+	n, _ := f.Child(0)
+	return n
+}
+
+// String 实现String接口
+func (f ForClauseNode) String() string {
+	if e := f.Expr(); e != nil {
+		return e.String()
+	}
+	return ""
+}
+
+// Eval 获取计算值, 省略时为nil
+func (f ForClauseNode) Eval(env Environment) interface{} {
+	return evalOptional(env, f.Expr())
+}
+
+// ForStatementNode C风格for循环, 如for (let i = 0; i < n; i = i + 1) { ... }
+type ForStatementNode struct {
+	BranchNode
+	layout *layout // 初始化部分中以let、const定义的变量, 由解析器填写
+}
+
+// NewForStatementNode 创建ForStatementNode
+func NewForStatementNode(list *list.ArrayList) ForStatementNode {
+	return ForStatementNode{NewBranchNode(list), &layout{}}
+}
+
+// clause 获取第index个部分
+func (f ForStatementNode) clause(index int) TreeNode {
+	n, _ := f.Child(index)
+	return n.(ForClauseNode).Expr()
+}
+
+// Init 初始化部分, 省略时返回nil
+func (f ForStatementNode) Init() TreeNode {
+	return f.clause(0)
+}
+
+// Condition 条件, 省略时返回nil
+func (f ForStatementNode) Condition() TreeNode {
+	return f.clause(1)
+}
+
+// Step 更新部分, 省略时返回nil
+func (f ForStatementNode) Step() TreeNode {
+	return f.clause(2)
+}
+
+// Body 循环体
+func (f ForStatementNode) Body() TreeNode {
+	n, _ := f.Child(3)
+	return n
+}
+
+// String 实现String接口
+func (f ForStatementNode) String() string {
+	parts := make([]string, 3)
+	for i := range parts {
+		n, _ := f.Child(i)
+		parts[i] = n.String()
+	}
+	return fmt.Sprintf("(for (%v; %v; %v) %v)", parts[0], parts[1], parts[2], f.Body())
+}
+
+// Eval 获取计算值, 初始化部分定义的变量只在循环中可见, 每次迭代前复制到新的环境中, 使闭包捕获各次迭代的值
+func (f ForStatementNode) Eval(env Environment) interface{} {
+	var frame *Frame
+	if f.layout.resolved {
+		frame = newFrame(f.layout, env)
+		env = frame
+	}
+	evalOptional(env, f.Init())
+	t := currentThread(env)
+	var result interface{}
+	for {
+		t.step(f)
+		if cond := f.Condition(); cond != nil && !Truthy(cond.Eval(env)) {
+			return result
+		}
+		if loopResult(f.Body().Eval(env), &result) {
+			return result
+		}
+		if frame != nil {
+			next := newFrame(f.layout, frame.outer)
+			frame.snapshot(next.values)
+			frame, env = next, next
+		}
+		evalOptional(env, f.Step())
+	}
+}
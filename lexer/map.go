@@ -0,0 +1,333 @@
+package lexer
+
+import (
+	"errors"
+	"simple-script-language/utils/list"
+	"strings"
+	"sync"
+)
+
+// Map 映射, 键为字符串或整数, 按插入顺序迭代; 可以在多个goroutine中同时访问
+type Map struct {
+	mu      sync.RWMutex
+	keys    []interface{}               // 按插入顺序保存的键
+	values  map[interface{}]interface{} // 键值
+	escaped bool                        // 是否可能被spawn创建的任务访问, 此后保存的值同样以escape标记
+}
+
+// NewMap 创建Map
+func NewMap() *Map {
+	return &Map{values: make(map[interface{}]interface{})}
+}
+
+// Get 获取值, 键不存在时返回false
+func (m *Map) Get(key interface{}) (interface{}, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	v, ok := m.values[key]
+	return v, ok
+}
+
+// Put 保存值, 新的键添加到结尾
+func (m *Map) Put(key interface{}, value interface{}) {
+	if m.escaped {
+		escape(value)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// escape 标记映射及其中的值可能被spawn创建的任务访问
+func (m *Map) escape() {
+	if m.escaped {
+		return
+	}
+	m.escaped = true
+	for _, v := range m.values {
+		escape(v)
+	}
+}
+
+// Delete 删除键, 键不存在时返回false
+func (m *Map) Delete(key interface{}) (interface{}, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.values[key]
+	if !ok {
+		return nil, false
+	}
+	delete(m.values, key)
+	for i, k := range m.keys {
+		if k == key {
+			m.keys = append(m.keys[:i], m.keys[i+1:]...)
+			break
+		}
+	}
+	return v, true
+}
+
+// Has 是否包含键
+func (m *Map) Has(key interface{}) bool {
+	_, ok := m.Get(key)
+	return ok
+}
+
+// Keys 按插入顺序获取所有键
+func (m *Map) Keys() []interface{} {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keys := make([]interface{}, len(m.keys))
+	copy(keys, m.keys)
+	return keys
+}
+
+// Size 键值对个数
+func (m *Map) Size() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.keys)
+}
+
+// For 按插入顺序遍历
+func (m *Map) For(handler func(key interface{}, value interface{})) {
+	for _, k := range m.Keys() {
+		if v, ok := m.Get(k); ok {
+			handler(k, v)
+		}
+	}
+}
+
+// isMapKey 是否可作为键, 只支持字符串和整数
+func isMapKey(key interface{}) bool {
+	switch key.(type) {
+	case string, int:
+		return true
+	}
+	return false
+}
+
+// mapKey 检查键的类型
+func mapKey(node TreeNode, key interface{}) interface{} {
+	if !isMapKey(key) {
+		panic(NewTypeError(node, "bad map key: %v", ToString(key)))
+	}
+	return key
+}
+
+// MapLiteralNode 映射字面量, 如{"a": 1, "b": 2}
+type MapLiteralNode struct {
+	BranchNode
+}
+
+// NewMapLiteralNode 创建MapLiteralNode
+func NewMapLiteralNode(list *list.ArrayList) MapLiteralNode {
+	return MapLiteralNode{NewBranchNode(list)}
+}
+
+// Size 键值对个数
+func (m MapLiteralNode) Size() int {
+	return m.ChildSize()
+}
+
+// String 实现String接口
+func (m MapLiteralNode) String() string {
+	entries := make([]string, 0, m.ChildSize())
+	m.Children().For(func(k int, v interface{}) {
+		entries = append(entries, v.(TreeNode).String())
+	})
+	return "{" + strings.Join(entries, ", ") + "}"
+}
+
+// Eval 获取计算值, 映射以*Map表示
+func (m MapLiteralNode) Eval(env Environment) interface{} {
+	allocate(env, m)
+	result := NewMap()
+	m.Children().For(func(k int, v interface{}) {
+		entry := v.(MapEntryNode)
+		result.Put(mapKey(entry.Key(), entry.Key().Eval(env)), entry.Value().Eval(env))
+	})
+	return result
+}
+
+// Build 以按顺序排列的键和值创建映射, values依次为第一个键、第一个值、第二个键...
+func (m MapLiteralNode) Build(values []Value) *Map {
+	result := NewMap()
+	for i := 0; i+1 < len(values); i += 2 {
+		n, _ := m.Child(i / 2)
+		result.Put(mapKey(n.(MapEntryNode).Key(), values[i]), values[i+1])
+	}
+	return result
+}
+
+// MapEntryNode 映射字面量中的键值对
+type MapEntryNode struct {
+	BranchNode
+}
+
+// NewMapEntryNode 创建MapEntryNode
+func NewMapEntryNode(list *list.ArrayList) MapEntryNode {
+	return MapEntryNode{NewBranchNode(list)}
+}
+
+// Key 获取键表达式
+func (m MapEntryNode) Key() TreeNode {
+	n, _ := m.Child(0)
+	return n
+}
+
+// Value 获取值表达式
+func (m MapEntryNode) Value() TreeNode {
+	n, _ := m.Child(1)
+	return n
+}
+
+// String 实现String接口
+func (m MapEntryNode) String() string {
+	return m.Key().String() + ": " + m.Value().String()
+}
+
+// DotNode 成员访问后缀, 如m.k、obj.field
+type DotNode struct {
+	BranchNode
+}
+
+// NewDotNode 创建DotNode
+func NewDotNode(list *list.ArrayList) DotNode {
+	return DotNode{NewBranchNode(list)}
+}
+
+// Name 获取成员名
+func (d DotNode) Name() string {
+	n, _ := d.Child(0)
+	return n.(LeafNode).token.GetText()
+}
+
+// String 实现String接口
+func (d DotNode) String() string {
+	return "." + d.Name()
+}
+
+// EvalSub 以前面表达式的计算值获取成员
+func (d DotNode) EvalSub(env Environment, value interface{}) interface{} {
+	if c, ok := value.(*ClassInfo); ok && d.Name() == "new" {
+		t := currentThread(env)
+		t.alloc(d, 1)
+		return c.newObject(t)
+	}
+	return d.Get(value)
+}
+
+// Get 获取value的成员, Name.new创建类的实例
+func (d DotNode) Get(value interface{}) interface{} {
+	member := d.Name()
+	switch v := value.(type) {
+	case *Map:
+		if item, ok := v.Get(member); ok {
+			return item
+		}
+		panic(NewKeyError(d, member))
+	case *ClassInfo:
+		if member == "new" {
+			return v.newObject(currentThread(nil))
+		}
+	case *Object:
+		if item, ok := v.Read(member); ok {
+			return item
+		}
+	case *Super:
+		if item, ok := v.Read(member); ok {
+			return item
+		}
+	case *ErrorValue:
+		if item, ok := v.Read(member); ok {
+			return item
+		}
+	case *Channel:
+		if item, ok := v.Read(member); ok {
+			return item
+		}
+	case *GoObject:
+		item, err := v.Read(member)
+		if err != nil {
+			panic(NewRuntimeError(d, "bad member access: %v", err))
+		}
+		return item
+	default:
+		panic(NewTypeError(d, "bad member access: %v", member))
+	}
+	panic(NewRuntimeError(d, "bad member access: %v", member))
+}
+
+// Assign 为成员赋值
+func (d DotNode) Assign(env Environment, value interface{}, rightVal interface{}) interface{} {
+	switch v := value.(type) {
+	case *Map:
+		v.Put(d.Name(), rightVal)
+		return rightVal
+	case *Object:
+		if v.env.isConst(d.Name()) {
+			panic(NewTypeError(d, "cannot assign to constant: %v", d.Name()))
+		}
+		v.Write(d.Name(), rightVal)
+		return rightVal
+	case *GoObject:
+		if err := v.Write(d.Name(), rightVal); err != nil {
+			var conv *conversionError
+			if errors.As(err, &conv) {
+				panic(NewTypeError(d, "%v: %v", d.Name(), err))
+			}
+			panic(NewRuntimeError(d, "bad member access: %v", err))
+		}
+		return rightVal
+	}
+	panic(NewTypeError(d, "bad member access: %v", d.Name()))
+}
+
+// Delete 删除成员
+func (d DotNode) Delete(env Environment, value interface{}) interface{} {
+	if m, ok := value.(*Map); ok {
+		if v, ok := m.Delete(d.Name()); ok {
+			return v
+		}
+		panic(NewKeyError(d, d.Name()))
+	}
+	panic(NewTypeError(d, "bad member access: %v", d.Name()))
+}
+
+// DelStatementNode 删除语句, 如del m["k"]、del m.k、del a[i]
+type DelStatementNode struct {
+	BranchNode
+}
+
+// NewDelStatementNode 创建DelStatementNode
+func NewDelStatementNode(list *list.ArrayList) DelStatementNode {
+	return DelStatementNode{NewBranchNode(list)}
+}
+
+// Target 获取删除的对象
+func (d DelStatementNode) Target() TreeNode {
+	n, _ := d.Child(0)
+	return n
+}
+
+// String 实现String接口
+func (d DelStatementNode) String() string {
+	return "(del " + d.Target().String() + ")"
+}
+
+// Eval 删除映射的键或数组的元素, 返回被删除的值
+func (d DelStatementNode) Eval(env Environment) interface{} {
+	if p, ok := d.Target().(PrimaryExpr); ok {
+		switch postfix := p.Postfix(0).(type) {
+		case ArrayRefNode:
+			return postfix.Delete(env, p.EvalSubExpr(env, 1))
+		case DotNode:
+			return postfix.Delete(env, p.EvalSubExpr(env, 1))
+		}
+	}
+	panic(NewRuntimeError(d, "bad del target"))
+}
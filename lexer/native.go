@@ -0,0 +1,494 @@
+package lexer
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"simple-script-language/utils/list"
+	"sort"
+)
+
+// Value 脚本中的值, 为nil、bool、int、float64、*big.Int、string、*list.ArrayList、*Map、
+// *Function、*NativeFunction、*ClassInfo、*Object或*GoObject
+type Value = interface{}
+
+// NativeFunc 宿主提供的函数, 返回的error作为脚本的运行时错误
+type NativeFunc func(args ...Value) (Value, error)
+
+// NativeFunction 宿主(Go)函数对象
+type NativeFunction struct {
+	name      string     // 函数名
+	params    int        // 最少参数个数
+	maxParams int        // 最多参数个数, 为负数时不限
+	fn        NativeFunc // 函数实现
+	threaded  threadFunc // 在调用者的执行状态中执行的函数实现, 不为nil时代替fn
+}
+
+// threadFunc 可能阻塞或创建较大的值的内置函数, 在调用者的执行状态t中执行;
+// 阻塞时应在执行被取消后以node的位置返回错误, 创建较大的值时应按大小计数分配并定期检查是否已取消
+type threadFunc func(t *thread, node TreeNode, args []Value) (Value, error)
+
+// NewNativeFunction 创建NativeFunction, 接受任意个数的参数, 由fn自行检查
+func NewNativeFunction(name string, fn NativeFunc) *NativeFunction {
+	return &NativeFunction{name: name, maxParams: -1, fn: fn}
+}
+
+// newBuiltin 创建参数个数在[params, maxParams]之间的NativeFunction
+func newBuiltin(name string, params, maxParams int, fn NativeFunc) *NativeFunction {
+	return &NativeFunction{name: name, params: params, maxParams: maxParams, fn: fn}
+}
+
+// NewGoFunction 以反射包装任意Go函数, 调用时按参数类型转换实参并检查参数个数,
+// 函数最后一个返回值为error时作为运行时错误, 其余多个返回值以数组返回
+func NewGoFunction(name string, fn interface{}) (*NativeFunction, error) {
+	fv := reflect.ValueOf(fn)
+	if fv.Kind() != reflect.Func {
+		return nil, fmt.Errorf("%v: not a function: %T", name, fn)
+	}
+	return newGoFunction(name, fv, nil), nil
+}
+
+// newGoFunction 以反射包装Go函数, 返回值中的结构体按allow创建代理
+func newGoFunction(name string, fv reflect.Value, allow *AllowList) *NativeFunction {
+	ft := fv.Type()
+	params := ft.NumIn()
+	if ft.IsVariadic() {
+		params--
+	}
+	call := func(args ...Value) (Value, error) {
+		in := make([]reflect.Value, len(args))
+		for i, arg := range args {
+			var t reflect.Type
+			if ft.IsVariadic() && i >= params {
+				t = ft.In(params).Elem()
+			} else {
+				t = ft.In(i)
+			}
+			v, err := toGoValue(arg, t)
+			if err != nil {
+				return nil, &argumentError{i + 1, err}
+			}
+			in[i] = v
+		}
+		return fromGoResults(fv.Call(in), allow)
+	}
+	maxParams := params
+	if ft.IsVariadic() {
+		maxParams = -1
+	}
+	return &NativeFunction{name: name, params: params, maxParams: maxParams, fn: call}
+}
+
+// Name 函数名
+func (n *NativeFunction) Name() string {
+	return n.name
+}
+
+// String String方法
+func (n *NativeFunction) String() string {
+	return fmt.Sprintf("<native:%v>", n.name)
+}
+
+// newThreaded 创建参数个数在[params, maxParams]之间、在调用者的执行状态中执行的NativeFunction
+func newThreaded(name string, params, maxParams int, fn threadFunc) *NativeFunction {
+	return &NativeFunction{name: name, params: params, maxParams: maxParams, threaded: fn}
+}
+
+// Call 检查参数个数并调用, node为调用处的节点, 出错时抛出运行时错误; 在不受限制的执行状态中执行, 不受任何执行的限制及取消影响
+func (n *NativeFunction) Call(node TreeNode, args []Value) Value {
+	return n.call(nil, node, args)
+}
+
+// call 在env所属的执行状态中调用, 受该执行的限制及取消影响
+func (n *NativeFunction) call(env Environment, node TreeNode, args []Value) Value {
+	var t *thread
+	if n.threaded != nil {
+		t = currentThread(env)
+	}
+	return n.run(t, node, args)
+}
+
+// run 检查参数个数后在执行状态t中调用, 以newThreaded创建之外的函数忽略t
+func (n *NativeFunction) run(t *thread, node TreeNode, args []Value) Value {
+	switch {
+	case n.params == n.maxParams && len(args) != n.params:
+		panic(NewArityError(node, n.params, len(args)))
+	case n.maxParams < 0 && len(args) < n.params:
+		panic(&ArityError{
+			newRuntimeError("ArityError", node, "bad number of arguments: expected at least %v, got %v", n.params, len(args)),
+			n.params,
+			len(args),
+		})
+	case len(args) < n.params || (n.maxParams >= 0 && len(args) > n.maxParams):
+		panic(&ArityError{
+			newRuntimeError("ArityError", node, "bad number of arguments: expected %v to %v, got %v", n.params, n.maxParams, len(args)),
+			n.params,
+			len(args),
+		})
+	}
+	var result Value
+	var err error
+	if n.threaded != nil {
+		result, err = n.threaded(t, node, args)
+	} else {
+		result, err = n.fn(args...)
+	}
+	if err != nil {
+		var argErr *argumentError
+		var runtimeErr *RuntimeError
+		switch {
+		case errors.As(err, &argErr):
+			panic(NewTypeError(node, "%v: argument %v: %v", n.name, argErr.index, argErr.err))
+		case errors.As(err, &runtimeErr):
+			// 宿主函数中调用脚本时产生的错误
+			panic(err)
+		}
+		panic(NewNativeError(node, n.name, err))
+	}
+	return result
+}
+
+// argumentError 实参无法转换为Go函数的参数类型
+type argumentError struct {
+	index int
+	err   error
+}
+
+// Error 实现error接口
+func (e *argumentError) Error() string {
+	return fmt.Sprintf("argument %v: %v", e.index, e.err)
+}
+
+// RegisterFunc 注册宿主函数
+func (b BasicEnvironment) RegisterFunc(name string, fn NativeFunc) {
+	b.PutNew(name, NewNativeFunction(name, fn))
+}
+
+// RegisterGo 以反射注册任意Go函数, fn不是函数时返回错误
+func (b BasicEnvironment) RegisterGo(name string, fn interface{}) error {
+	return registerGo(b, name, fn)
+}
+
+// RegisterFunc 注册宿主函数
+func (n *NestedEnvironment) RegisterFunc(name string, fn NativeFunc) {
+	n.PutNew(name, NewNativeFunction(name, fn))
+}
+
+// RegisterGo 以反射注册任意Go函数, fn不是函数时返回错误
+func (n *NestedEnvironment) RegisterGo(name string, fn interface{}) error {
+	return registerGo(n, name, fn)
+}
+
+// RegisterValue 注册Go值, 结构体以allow限制脚本可访问的成员
+func (b BasicEnvironment) RegisterValue(name string, v interface{}, allow *AllowList) error {
+	return registerValue(b, name, v, allow)
+}
+
+// RegisterValue 注册Go值, 结构体以allow限制脚本可访问的成员
+func (n *NestedEnvironment) RegisterValue(name string, v interface{}, allow *AllowList) error {
+	return registerValue(n, name, v, allow)
+}
+
+// registerValue 在env中注册Go值
+func registerValue(env Environment, name string, v interface{}, allow *AllowList) error {
+	value, err := ToValue(v, allow)
+	if err != nil {
+		return err
+	}
+	env.PutNew(name, value)
+	return nil
+}
+
+// registerGo 在env中注册Go函数
+func registerGo(env Environment, name string, fn interface{}) error {
+	f, err := NewGoFunction(name, fn)
+	if err != nil {
+		return err
+	}
+	env.PutNew(name, f)
+	return nil
+}
+
+// maxInt int的最大值
+const maxInt = int(^uint(0) >> 1)
+
+// minInt int的最小值
+const minInt = -maxInt - 1
+
+var (
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+	bigIntType = reflect.TypeOf((*big.Int)(nil))
+)
+
+// toGoValue 将脚本中的值转换为t类型的Go值
+func toGoValue(v Value, t reflect.Type) (reflect.Value, error) {
+	if v == nil {
+		switch t.Kind() {
+		case reflect.Interface, reflect.Ptr, reflect.Slice, reflect.Map, reflect.Func:
+			return reflect.Zero(t), nil
+		}
+		return reflect.Value{}, conversionErrorf("cannot use nil as %v", t)
+	}
+	if g, ok := v.(*GoObject); ok {
+		switch {
+		case g.value.Type().AssignableTo(t):
+			return g.value, nil
+		case g.value.Kind() == reflect.Ptr && g.value.Type().Elem().AssignableTo(t):
+			return g.value.Elem(), nil
+		}
+		return reflect.Value{}, typeMismatch(v, t)
+	}
+	if t == bigIntType {
+		switch n := v.(type) {
+		case int:
+			return reflect.ValueOf(big.NewInt(int64(n))), nil
+		case *big.Int:
+			return reflect.ValueOf(n), nil
+		}
+	}
+	rv := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		switch i := v.(type) {
+		case int:
+			n = int64(i)
+		case *big.Int:
+			if !i.IsInt64() {
+				return rv, conversionErrorf("%v overflows %v", i, t)
+			}
+			n = i.Int64()
+		default:
+			return rv, typeMismatch(v, t)
+		}
+		if rv.OverflowInt(n) {
+			return rv, conversionErrorf("%v overflows %v", n, t)
+		}
+		rv.SetInt(n)
+		return rv, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var n uint64
+		switch i := v.(type) {
+		case int:
+			if i < 0 {
+				return rv, conversionErrorf("%v overflows %v", i, t)
+			}
+			n = uint64(i)
+		case *big.Int:
+			if !i.IsUint64() {
+				return rv, conversionErrorf("%v overflows %v", i, t)
+			}
+			n = i.Uint64()
+		default:
+			return rv, typeMismatch(v, t)
+		}
+		if rv.OverflowUint(n) {
+			return rv, conversionErrorf("%v overflows %v", n, t)
+		}
+		rv.SetUint(n)
+		return rv, nil
+	case reflect.Float32, reflect.Float64:
+		if !isNumber(v) {
+			return rv, typeMismatch(v, t)
+		}
+		rv.SetFloat(toFloat(v))
+		return rv, nil
+	case reflect.String:
+		if s, ok := v.(string); ok {
+			rv.SetString(s)
+			return rv, nil
+		}
+	case reflect.Bool:
+		if b, ok := v.(bool); ok {
+			rv.SetBool(b)
+			return rv, nil
+		}
+	case reflect.Slice:
+		if a, ok := v.(*list.ArrayList); ok {
+			rv = reflect.MakeSlice(t, a.Size(), a.Size())
+			var err error
+			a.For(func(k int, item interface{}) {
+				if err != nil {
+					return
+				}
+				var e reflect.Value
+				if e, err = toGoValue(item, t.Elem()); err == nil {
+					rv.Index(k).Set(e)
+				}
+			})
+			return rv, err
+		}
+	case reflect.Map:
+		if m, ok := v.(*Map); ok {
+			rv = reflect.MakeMapWithSize(t, m.Size())
+			var err error
+			m.For(func(key interface{}, value interface{}) {
+				if err != nil {
+					return
+				}
+				var k, e reflect.Value
+				if k, err = toGoValue(key, t.Key()); err != nil {
+					return
+				}
+				if e, err = toGoValue(value, t.Elem()); err == nil {
+					rv.SetMapIndex(k, e)
+				}
+			})
+			return rv, err
+		}
+	}
+	if value := reflect.ValueOf(v); value.Type().AssignableTo(t) {
+		rv.Set(value)
+		return rv, nil
+	}
+	return rv, typeMismatch(v, t)
+}
+
+// conversionError 脚本中的值无法转换为Go类型的错误
+type conversionError struct {
+	msg string
+}
+
+// Error 实现error接口
+func (e *conversionError) Error() string {
+	return e.msg
+}
+
+// conversionErrorf 创建conversionError
+func conversionErrorf(format string, a ...interface{}) error {
+	return &conversionError{fmt.Sprintf(format, a...)}
+}
+
+// typeMismatch 类型不匹配的错误
+func typeMismatch(v Value, t reflect.Type) error {
+	return conversionErrorf("cannot use %v as %v", toString(v, true, nil), t)
+}
+
+// fromGoResults 转换Go函数的返回值, 最后一个返回值为error且不为nil时返回该错误
+func fromGoResults(out []reflect.Value, allow *AllowList) (Value, error) {
+	if n := len(out); n > 0 && out[n-1].Type() == errorType {
+		if !out[n-1].IsNil() {
+			return nil, out[n-1].Interface().(error)
+		}
+		out = out[:n-1]
+	}
+	switch len(out) {
+	case 0:
+		return nil, nil
+	case 1:
+		return fromGoValue(out[0], allow)
+	}
+	results := list.New(len(out))
+	for _, v := range out {
+		r, err := fromGoValue(v, allow)
+		if err != nil {
+			return nil, err
+		}
+		results.Add(r)
+	}
+	return results, nil
+}
+
+// fromGoValue 将Go值转换为脚本中的值, 切片和数组转换为*list.ArrayList, 映射转换为*Map,
+// 结构体按allow转换为*GoObject
+func fromGoValue(v reflect.Value, allow *AllowList) (Value, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+	if v.Type() == bigIntType {
+		if v.IsNil() {
+			return nil, nil
+		}
+		return normalize(v.Interface().(*big.Int)), nil
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := v.Int()
+		if int64(int(n)) != n {
+			return big.NewInt(n), nil
+		}
+		return int(n), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n := v.Uint()
+		if n > uint64(maxInt) {
+			return new(big.Int).SetUint64(n), nil
+		}
+		return int(n), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return nil, nil
+		}
+		if v.Kind() == reflect.Interface {
+			return fromGoValue(v.Elem(), allow)
+		}
+		if isStruct(v) {
+			return &GoObject{v, allow}, nil
+		}
+	case reflect.Struct:
+		// 可寻址时代理其指针, 以便修改字段
+		if v.CanAddr() {
+			return &GoObject{v.Addr(), allow}, nil
+		}
+		return &GoObject{v, allow}, nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil, nil
+		}
+		result := list.New(v.Len())
+		for i := 0; i < v.Len(); i++ {
+			item, err := fromGoValue(v.Index(i), allow)
+			if err != nil {
+				return nil, err
+			}
+			result.Add(item)
+		}
+		return result, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, nil
+		}
+		// Go的映射无序, 按键排序后保存, 整数在字符串之前
+		keys := make([]interface{}, 0, v.Len())
+		values := make(map[interface{}]reflect.Value, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key, err := fromGoValue(iter.Key(), allow)
+			if err != nil {
+				return nil, err
+			}
+			if !isMapKey(key) {
+				return nil, fmt.Errorf("bad map key: %v", toString(key, true, nil))
+			}
+			keys = append(keys, key)
+			values[key] = iter.Value()
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			a, aok := keys[i].(int)
+			b, bok := keys[j].(int)
+			if aok && bok {
+				return a < b
+			}
+			if aok || bok {
+				return aok
+			}
+			return keys[i].(string) < keys[j].(string)
+		})
+		result := NewMap()
+		for _, key := range keys {
+			value, err := fromGoValue(values[key], allow)
+			if err != nil {
+				return nil, err
+			}
+			result.Put(key, value)
+		}
+		return result, nil
+	}
+	return v.Interface(), nil
+}
@@ -0,0 +1,221 @@
+package lexer
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// 数值类型为int、float64和*big.Int, 整数运算溢出时转换为*big.Int,
+// 结果在int范围内的*big.Int转换回int, 整数与浮点数运算时转换为浮点数
+
+// isNumber 是否为数值
+func isNumber(v interface{}) bool {
+	switch v.(type) {
+	case int, float64, *big.Int:
+		return true
+	}
+	return false
+}
+
+// toFloat 数值转换为浮点数
+func toFloat(v interface{}) float64 {
+	switch v := v.(type) {
+	case int:
+		return float64(v)
+	case float64:
+		return v
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(v).Float64()
+		return f
+	}
+	return 0
+}
+
+// toBig 整数转换为*big.Int
+func toBig(v interface{}) *big.Int {
+	switch v := v.(type) {
+	case int:
+		return big.NewInt(int64(v))
+	case *big.Int:
+		return v
+	}
+	return nil
+}
+
+// normalize 在int范围内的*big.Int转换为int
+func normalize(n *big.Int) interface{} {
+	if n.IsInt64() {
+		if v := n.Int64(); int64(int(v)) == v {
+			return int(v)
+		}
+	}
+	return n
+}
+
+// formatFloat 浮点数的字符串形式, 整数值保留".0"
+func formatFloat(f float64) string {
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eEIN") {
+		s += ".0"
+	}
+	return s
+}
+
+// computeNumber 数值计算
+func (b BinaryExprNode) computeNumber(left interface{}, op string, right interface{}) interface{} {
+	if _, ok := left.(float64); ok {
+		return b.computeFloat(left.(float64), op, toFloat(right))
+	}
+	if _, ok := right.(float64); ok {
+		return b.computeFloat(toFloat(left), op, right.(float64))
+	}
+	nl, lok := left.(int)
+	nr, rok := right.(int)
+	if lok && rok {
+		if v, ok := b.computeInt(nl, op, nr); ok {
+			return v
+		}
+	}
+	return b.computeBig(toBig(left), op, toBig(right))
+}
+
+// computeInt 整型计算, 溢出时返回false
+func (b BinaryExprNode) computeInt(left int, op string, right int) (interface{}, bool) {
+	switch op {
+	case "+":
+		r := left + right
+		return r, (r > left) == (right > 0)
+	case "-":
+		r := left - right
+		return r, (r < left) == (right > 0)
+	case "*":
+		if left == 0 || right == 0 {
+			return 0, true
+		}
+		r := left * right
+		return r, r/right == left && !(left == -1 && right == minInt) && !(right == -1 && left == minInt)
+	case "/":
+		b.checkZero(right == 0)
+		return left / right, !(left == minInt && right == -1)
+	case "%":
+		b.checkZero(right == 0)
+		return left % right, true
+	case "==":
+		return left == right, true
+	case "!=":
+		return left != right, true
+	case ">":
+		return left > right, true
+	case "<":
+		return left < right, true
+	case ">=":
+		return left >= right, true
+	case "<=":
+		return left <= right, true
+	}
+	panic(NewRuntimeError(b.operatorNode(), "bad operator: %v", op))
+}
+
+// computeBig 大整数计算
+func (b BinaryExprNode) computeBig(left *big.Int, op string, right *big.Int) interface{} {
+	switch op {
+	case "+":
+		return normalize(new(big.Int).Add(left, right))
+	case "-":
+		return normalize(new(big.Int).Sub(left, right))
+	case "*":
+		return normalize(new(big.Int).Mul(left, right))
+	case "/":
+		b.checkZero(right.Sign() == 0)
+		return normalize(new(big.Int).Quo(left, right))
+	case "%":
+		b.checkZero(right.Sign() == 0)
+		return normalize(new(big.Int).Rem(left, right))
+	case "==":
+		return left.Cmp(right) == 0
+	case "!=":
+		return left.Cmp(right) != 0
+	case ">":
+		return left.Cmp(right) > 0
+	case "<":
+		return left.Cmp(right) < 0
+	case ">=":
+		return left.Cmp(right) >= 0
+	case "<=":
+		return left.Cmp(right) <= 0
+	}
+	panic(NewRuntimeError(b.operatorNode(), "bad operator: %v", op))
+}
+
+// computeFloat 浮点数计算
+func (b BinaryExprNode) computeFloat(left float64, op string, right float64) interface{} {
+	switch op {
+	case "+":
+		return left + right
+	case "-":
+		return left - right
+	case "*":
+		return left * right
+	case "/":
+		b.checkZero(right == 0)
+		return left / right
+	case "%":
+		b.checkZero(right == 0)
+		return math.Mod(left, right)
+	case "==":
+		return left == right
+	case "!=":
+		return left != right
+	case ">":
+		return left > right
+	case "<":
+		return left < right
+	case ">=":
+		return left >= right
+	case "<=":
+		return left <= right
+	}
+	panic(NewRuntimeError(b.operatorNode(), "bad operator: %v", op))
+}
+
+// checkZero 除数为0时抛出ZeroDivisionError
+func (b BinaryExprNode) checkZero(zero bool) {
+	if zero {
+		panic(NewZeroDivisionError(b.operatorNode()))
+	}
+}
+
+// compareNumbers 比较两个数值, 返回-1、0或1
+func compareNumbers(left interface{}, right interface{}) int {
+	_, lf := left.(float64)
+	_, rf := right.(float64)
+	if lf || rf {
+		l, r := toFloat(left), toFloat(right)
+		switch {
+		case l < r:
+			return -1
+		case l > r:
+			return 1
+		}
+		return 0
+	}
+	return toBig(left).Cmp(toBig(right))
+}
+
+// negate 取相反数
+func negate(v interface{}) (interface{}, bool) {
+	switch v := v.(type) {
+	case int:
+		if v == minInt {
+			return new(big.Int).Neg(toBig(v)), true
+		}
+		return -v, true
+	case float64:
+		return -v, true
+	case *big.Int:
+		return normalize(new(big.Int).Neg(v)), true
+	}
+	return nil, false
+}
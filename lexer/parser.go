@@ -1,6 +1,8 @@
 package lexer
 
 import (
+	"fmt"
+
 	"github.com/deckarep/golang-set"
 	"simple-script-language/utils/list"
 )
@@ -39,6 +41,7 @@ func (o Operators) Add(name string, prec int, leftAssoc bool) {
 // BasicParser 语法解析器
 type BasicParser struct {
 	reserved   mapset.Set
+	sync       mapset.Set
 	operators  Operators
 	parser     *Parser
 	primary    *Parser
@@ -54,36 +57,54 @@ type BasicParser struct {
 // NewBasicParser 创建Parser对象
 func NewBasicParser() BasicParser {
 	reserved := mapset.NewSet(";", "}", EOL)
+	// 语句边界, 错误恢复时跳过至这些单词
+	sync := reserved.Clone()
+	reserved.Add("true")
+	reserved.Add("false")
+	reserved.Add("nil")
 	operators := NewOperators()
 	operators.Add("=", 1, RIGHT)
-	operators.Add("==", 2, LEFT)
-	operators.Add(">", 2, LEFT)
-	operators.Add("<", 2, LEFT)
-	operators.Add("+", 3, LEFT)
-	operators.Add("-", 3, LEFT)
-	operators.Add("*", 4, LEFT)
-	operators.Add("/", 4, LEFT)
-	operators.Add("%", 4, LEFT)
+	operators.Add("||", 2, LEFT)
+	operators.Add("&&", 3, LEFT)
+	operators.Add("==", 4, LEFT)
+	operators.Add("!=", 4, LEFT)
+	operators.Add(">", 5, LEFT)
+	operators.Add("<", 5, LEFT)
+	operators.Add(">=", 5, LEFT)
+	operators.Add("<=", 5, LEFT)
+	operators.Add("+", 6, LEFT)
+	operators.Add("-", 6, LEFT)
+	operators.Add("*", 7, LEFT)
+	operators.Add("/", 7, LEFT)
+	operators.Add("%", 7, LEFT)
 
 	expr0 := Rule()
 	primary := RuleByType(NewPrimaryExpr(list.New(0))).Or([]*Parser{
 		Rule().Sep("(").Ast(expr0).Sep(")"),
 		Rule().Number(NewNumberNode(nil)),
+		Rule().Keyword(NewBoolNode(nil), "true", "false"),
+		Rule().Keyword(NewNilNode(nil), "nil"),
 		Rule().Identifier(NewVariableNode(nil), reserved),
 		Rule().String(NewStringNode(nil)),
 	})
-	factor := Rule().Or([]*Parser{
+	factor := Rule()
+	factor.Or([]*Parser{
 		RuleByType(NewNegativeExprNode(list.New(0))).Sep("-").Ast(primary),
+		RuleByType(NewNotExprNode(list.New(0))).Sep("!").Ast(factor),
 		primary,
 	})
 	expr := expr0.Expression(NewBinaryExprNode(list.New(0)), factor, operators)
 	statement0 := Rule()
-	block := RuleByType(NewBlockStatementNode(list.New(0))).Sep("{").Option(statement0).Repeat(Rule().Sep(";", EOL).Option(statement0)).Sep("}")
+	blockStatement := Rule().Recover(statement0, sync)
+	block := RuleByType(NewBlockStatementNode(list.New(0))).Sep("{").Option(blockStatement).Repeat(Rule().Sep(";", EOL).Option(blockStatement)).Sep("}")
 	simple := RuleByType(NewPrimaryExpr(list.New(0))).Ast(expr)
 	statement := statement0.Or([]*Parser{
 		RuleByType(NewIfStatementNode(list.New(0))).Sep("if").Ast(expr).Ast(block).Option(
 			Rule().Sep("else").Ast(block)),
 		RuleByType(NewWhileStatementNode(list.New(0))).Sep("while").Ast(expr).Ast(block),
+		RuleByType(NewReturnStatementNode(list.New(0))).Token("return").Option(expr),
+		RuleByType(NewBreakStatementNode(list.New(0))).Token("break"),
+		RuleByType(NewContinueStatementNode(list.New(0))).Token("continue"),
 		simple,
 	})
 	program := Rule().Or([]*Parser{
@@ -92,6 +113,7 @@ func NewBasicParser() BasicParser {
 	}).Sep(";", EOL)
 	return BasicParser{
 		reserved:   reserved,
+		sync:       sync,
 		operators:  operators,
 		parser:     expr0,
 		primary:    primary,
@@ -107,7 +129,81 @@ func NewBasicParser() BasicParser {
 
 // Parser 解析
 func (b BasicParser) Parser(lexer *Lexer) TreeNode {
-	return b.program.parse(lexer)
+	node := b.program.parse(lexer)
+	checkJumps(node, false, false, func(err *SyntaxError) {
+		panic(err)
+	})
+	return node
+}
+
+// ParserWithRecovery 解析, 出错时记录错误并跳过该语句, 返回ErrorNode
+func (b BasicParser) ParserWithRecovery(lexer *Lexer) (node TreeNode) {
+	lexer.SetRecovery(true)
+	defer func() {
+		if e := recover(); e != nil {
+			err, ok := e.(*SyntaxError)
+			if !ok {
+				panic(e)
+			}
+			lexer.addError(err)
+			SkipTo(lexer, b.sync)
+			// 跳过语句结尾, 以及多余的"}"
+			lexer.Read()
+			node = NewErrorNode(err)
+		}
+	}()
+	node = b.program.parse(lexer)
+	checkJumps(node, false, false, lexer.addError)
+	return node
+}
+
+// checkJumps 检查return只出现在函数中, break和continue只出现在循环中
+func checkJumps(node TreeNode, inFunc, inLoop bool, report func(err *SyntaxError)) {
+	var keyword string
+	switch node.(type) {
+	case DefStatementNode, FunNode:
+		inFunc, inLoop = true, false
+	case ClassBodyNode:
+		inFunc, inLoop = false, false
+	case WhileStatementNode, ForInStatementNode, ForStatementNode:
+		inLoop = true
+	case ReturnStatementNode:
+		if !inFunc {
+			keyword = "return"
+		}
+	case BreakStatementNode:
+		if !inLoop {
+			keyword = "break"
+		}
+	case ContinueStatementNode:
+		if !inLoop {
+			keyword = "continue"
+		}
+	case TryStatementNode:
+		if n := node.(TryStatementNode); n.Catch().Empty() && n.Finally().Body() == nil {
+			report(newSyntaxError("try without catch or finally", firstToken(node)))
+		}
+	case SpawnNode:
+		n := node.(SpawnNode)
+		if _, ok := n.Call(); !ok {
+			report(newSyntaxError(n.Keyword()+" requires a function call", firstToken(node)))
+		}
+	}
+	if keyword != "" {
+		msg := fmt.Sprintf("%v outside %v", keyword, jumpScope(keyword))
+		report(newSyntaxError(msg, firstToken(node)))
+	}
+	node.Children().For(func(k int, v interface{}) {
+		checkJumps(v.(TreeNode), inFunc, inLoop, report)
+	})
+}
+
+// jumpScope return、break、continue可以出现的位置
+func jumpScope(keyword string) string {
+	if keyword == "return" {
+		return "function"
+	}
+	return "loop"
 }
 
 // FuncParser 函数解析器
@@ -117,6 +213,7 @@ type FuncParser struct {
 	params    *Parser
 	paramList *Parser
 	def       *Parser
+	closure   *Parser
 	args      *Parser
 	postfix   *Parser
 }
@@ -128,20 +225,201 @@ func NewFuncParser() FuncParser {
 	params := RuleByType(NewParameterListNode(list.New(0))).Ast(param).Repeat(Rule().Sep(",").Ast(param))
 	paramList := Rule().Sep("(").Maybe(params).Sep(")")
 	def := RuleByType(NewDefStatementNode(list.New(0))).Sep("def").Identifier(nil, bp.reserved).Ast(paramList).Ast(bp.block)
-	args := RuleByType(NewArgumentsNode(list.New(0))).Ast(bp.expr).Repeat(Rule().Sep(",").Ast(bp.expr))
-	postfix := Rule().Sep("(").Maybe(args).Sep(")")
+	closure := RuleByType(NewFunNode(list.New(0))).Sep("fun").Ast(paramList).Ast(bp.block)
+	eol := Rule().Sep(EOL)
+	args := RuleByType(NewArgumentsNode(list.New(0))).Ast(bp.expr).Repeat(Rule().Sep(",").Repeat(eol).Ast(bp.expr))
+	// 括号中的实参可以跨行
+	postfix := Rule().Sep("(").Repeat(eol).Maybe(args).Repeat(eol).Sep(")")
 
 	bp.reserved.Add(")")
+	bp.primary.InsertChoice(closure)
 	bp.primary.Repeat(postfix)
 	bp.simple.Option(args)
-	bp.program.InsertChoice(def)
+	// 函数定义可以出现在代码块中, 以便定义内部函数
+	bp.statement.InsertChoice(def)
 	return FuncParser{
 		BasicParser: bp,
 		param:       param,
 		params:      params,
 		paramList:   paramList,
 		def:         def,
+		closure:     closure,
 		args:        args,
 		postfix:     postfix,
 	}
 }
+
+// ArrayParser 数组解析器
+type ArrayParser struct {
+	FuncParser
+	elements *Parser
+	arrayRef *Parser
+}
+
+// NewArrayParser 创建ArrayParser
+func NewArrayParser() ArrayParser {
+	fp := NewFuncParser()
+	eol := Rule().Sep(EOL)
+	elements := RuleByType(NewArrayLiteralNode(list.New(0))).Ast(fp.expr).Repeat(Rule().Sep(",").Repeat(eol).Ast(fp.expr))
+	slice := RuleByType(NewSliceNode(list.New(0))).Sep(":").Option(fp.expr)
+	arrayRef := RuleByType(NewArrayRefNode(list.New(0))).Sep("[").Or([]*Parser{slice, fp.expr}).Option(slice).Sep("]")
+
+	fp.reserved.Add("]")
+	fp.reserved.Add(":")
+	fp.primary.InsertChoice(Rule().Sep("[").Repeat(eol).Maybe(elements).Repeat(eol).Sep("]"))
+	fp.postfix.InsertChoice(arrayRef)
+	return ArrayParser{
+		FuncParser: fp,
+		elements:   elements,
+		arrayRef:   arrayRef,
+	}
+}
+
+// MapParser 映射解析器
+type MapParser struct {
+	ArrayParser
+	entry *Parser
+	dot   *Parser
+	del   *Parser
+}
+
+// NewMapParser 创建MapParser, 代码块只出现在if、while、def、fun之后,
+// 表达式中的"{"均为映射字面量
+func NewMapParser() MapParser {
+	ap := NewArrayParser()
+	eol := Rule().Sep(EOL)
+	entry := RuleByType(NewMapEntryNode(list.New(0))).Ast(ap.expr).Sep(":").Ast(ap.expr)
+	entries := RuleByType(NewMapLiteralNode(list.New(0))).Ast(entry).Repeat(Rule().Sep(",").Repeat(eol).Ast(entry))
+	mapLiteral := Rule().Sep("{").Repeat(eol).Maybe(entries).Repeat(eol).Sep("}")
+	dot := RuleByType(NewDotNode(list.New(0))).Sep(".").Identifier(nil, ap.reserved)
+	del := RuleByType(NewDelStatementNode(list.New(0))).Sep("del").Ast(ap.primary)
+
+	ap.reserved.Add("in")
+	ap.operators.Add("in", 5, LEFT)
+	ap.primary.InsertChoice(mapLiteral)
+	ap.postfix.InsertChoice(dot)
+	ap.statement.InsertChoice(del)
+	return MapParser{
+		ArrayParser: ap,
+		entry:       entry,
+		dot:         dot,
+		del:         del,
+	}
+}
+
+// ClassParser 类解析器
+type ClassParser struct {
+	MapParser
+	member    *Parser
+	classBody *Parser
+	defclass  *Parser
+}
+
+// NewClassParser 创建ClassParser, 成员访问使用MapParser中的"."后缀
+func NewClassParser() ClassParser {
+	mp := NewMapParser()
+	member := Rule().Or([]*Parser{mp.def, mp.simple})
+	classMember := Rule().Recover(member, mp.sync)
+	classBody := RuleByType(NewClassBodyNode(list.New(0))).Sep("{").Option(classMember).Repeat(Rule().Sep(";", EOL).Option(classMember)).Sep("}")
+	defclass := RuleByType(NewClassStatementNode(list.New(0))).Sep("class").Identifier(nil, mp.reserved).Option(
+		Rule().Sep("extends").Identifier(nil, mp.reserved)).Ast(classBody)
+
+	mp.statement.InsertChoice(defclass)
+	return ClassParser{
+		MapParser: mp,
+		member:    member,
+		classBody: classBody,
+		defclass:  defclass,
+	}
+}
+
+// DeclParser 变量声明解析器
+type DeclParser struct {
+	ClassParser
+	decl *Parser
+}
+
+// NewDeclParser 创建DeclParser, var在整个函数中可见, let和const只在所在代码块中可见;
+// 类体中的声明定义对象的字段, const定义的字段不能修改
+func NewDeclParser() DeclParser {
+	cp := NewClassParser()
+	decl := RuleByType(NewDeclStatementNode(list.New(0))).Token("var", "let", "const").Identifier(nil, cp.reserved).Option(
+		Rule().Sep("=").Ast(cp.expr))
+
+	cp.statement.InsertChoice(decl)
+	cp.member.InsertChoice(decl)
+	return DeclParser{
+		ClassParser: cp,
+		decl:        decl,
+	}
+}
+
+// LoopParser for循环解析器
+type LoopParser struct {
+	DeclParser
+	forIn   *Parser
+	forLoop *Parser
+}
+
+// NewLoopParser 创建LoopParser, 支持for x in a { ... }及for (init; cond; step) { ... }
+func NewLoopParser() LoopParser {
+	dp := NewDeclParser()
+	clause := func(p *Parser) *Parser {
+		return RuleByType(NewForClauseNode(list.New(0))).Ast(p)
+	}
+	init := Rule().Or([]*Parser{dp.decl, dp.expr})
+	forLoop := RuleByType(NewForStatementNode(list.New(0))).Sep("(").Maybe(clause(init)).Sep(";").Maybe(
+		clause(dp.expr)).Sep(";").Maybe(clause(dp.expr)).Sep(")").Ast(dp.block)
+	forIn := RuleByType(NewForInStatementNode(list.New(0))).Identifier(nil, dp.reserved).Sep("in").Ast(dp.expr).Ast(dp.block)
+
+	dp.statement.InsertChoice(Rule().Sep("for").Or([]*Parser{forLoop, forIn}))
+	return LoopParser{
+		DeclParser: dp,
+		forIn:      forIn,
+		forLoop:    forLoop,
+	}
+}
+
+// TryParser 异常处理解析器
+type TryParser struct {
+	LoopParser
+	throw *Parser
+	try   *Parser
+}
+
+// NewTryParser 创建TryParser, 支持throw语句及try { ... } catch (e) { ... } finally { ... }, catch和finally至少出现一个
+func NewTryParser() TryParser {
+	lp := NewLoopParser()
+	throw := RuleByType(NewThrowStatementNode(list.New(0))).Token("throw").Ast(lp.expr)
+	catch := RuleByType(NewCatchClauseNode(list.New(0))).Sep("catch").Sep("(").Identifier(nil, lp.reserved).Sep(")").Ast(lp.block)
+	finally := RuleByType(NewFinallyClauseNode(list.New(0))).Sep("finally").Ast(lp.block)
+	try := RuleByType(NewTryStatementNode(list.New(0))).Token("try").Ast(lp.block).Maybe(catch).Maybe(finally)
+
+	lp.statement.InsertChoice(throw)
+	lp.statement.InsertChoice(try)
+	return TryParser{
+		LoopParser: lp,
+		throw:      throw,
+		try:        try,
+	}
+}
+
+// SpawnParser 并发解析器
+type SpawnParser struct {
+	TryParser
+	spawn *Parser
+}
+
+// NewSpawnParser 创建SpawnParser, 支持spawn f(x)及go f(x), spawn和go成为关键字
+func NewSpawnParser() SpawnParser {
+	tp := NewTryParser()
+	spawn := RuleByType(NewSpawnNode(list.New(0))).Token("spawn", "go").Ast(tp.primary)
+
+	tp.reserved.Add("spawn")
+	tp.reserved.Add("go")
+	tp.primary.InsertChoice(spawn)
+	return SpawnParser{
+		TryParser: tp,
+		spawn:     spawn,
+	}
+}
@@ -0,0 +1,40 @@
+package lexer
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+// run 在新的解释器中执行源码, 返回输出及错误
+func run(t *testing.T, src string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	interp := NewInterpreter(Config{Out: &out})
+	_, err := interp.Exec(context.Background(), "test.ssl", strings.NewReader(src))
+	return out.String(), err
+}
+
+func TestMultilineLiterals(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"a = [\n1,\n2\n]\nprintln(a)", "[1, 2]\n"},
+		{"a = [\n]\nprintln(a)", "[]\n"},
+		{"println(1,\n2)", "1 2\n"},
+		{"println(\n  3,\n  4\n)", "3 4\n"},
+		{"m = {\n\"k\": [\n1,\n2]\n}\nprintln(m)", "{\"k\": [1, 2]}\n"},
+	}
+	for _, tt := range tests {
+		got, err := run(t, tt.src)
+		if err != nil {
+			t.Errorf("%q: %v", tt.src, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
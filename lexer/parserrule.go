@@ -1,7 +1,6 @@
 package lexer
 
 import (
-	"fmt"
 	mapset "github.com/deckarep/golang-set"
 	"simple-script-language/utils/list"
 )
@@ -22,15 +21,16 @@ func NewLeaf(pat []string) Leaf {
 	return Leaf{pat}
 }
 
-// Parse 解析
+// Parse 解析, 不匹配时不读取该单词
 func (l Leaf) Parse(lexer *Lexer, res *list.ArrayList) {
-	t, err := lexer.Read()
+	t, err := lexer.Peek(0)
 	if err != nil {
 		panic(err)
 	}
 	if t.IsIdentifier() {
 		for _, v := range l.tokens {
 			if v == t.GetText() {
+				lexer.Read()
 				l.find(res, t)
 				return
 			}
@@ -81,15 +81,16 @@ func NewSkip(pat []string) Skip {
 func (s Skip) find(res *list.ArrayList, token Token) {
 }
 
-// Parse 解析
+// Parse 解析, 不匹配时不读取该单词
 func (s Skip) Parse(lexer *Lexer, res *list.ArrayList) {
-	t, err := lexer.Read()
+	t, err := lexer.Peek(0)
 	if err != nil {
 		panic(err)
 	}
 	if t.IsIdentifier() {
 		for _, v := range s.tokens {
 			if v == t.GetText() {
+				lexer.Read()
 				s.find(res, t)
 				return
 			}
@@ -137,13 +138,14 @@ func NewAToken(node interface{}) AToken {
 	return AToken{factory: factory}
 }
 
-// Parse 解析
+// Parse 解析, 不匹配时不读取该单词
 func (a AToken) Parse(lexer *Lexer, res *list.ArrayList) {
-	t, err := lexer.Read()
+	t, err := lexer.Peek(0)
 	if err != nil {
 		panic(err)
 	}
 	if a.test(t) {
+		lexer.Read()
 		leaf := a.factory.make(t)
 		res.Add(leaf)
 	} else {
@@ -183,6 +185,29 @@ func NewIdTokenParser(typ interface{}, r mapset.Set) IdTokenParser {
 	return idToken
 }
 
+// KeywordParser 关键字解析器, 匹配指定的标识符
+type KeywordParser struct {
+	AToken
+}
+
+// NewKeywordParser 创建KeywordParser
+func NewKeywordParser(typ interface{}, words []string) KeywordParser {
+	aToken := NewAToken(typ)
+	keyword := KeywordParser{aToken}
+	keyword.test = func(token Token) bool {
+		if !token.IsIdentifier() {
+			return false
+		}
+		for _, w := range words {
+			if w == token.GetText() {
+				return true
+			}
+		}
+		return false
+	}
+	return keyword
+}
+
 // NumTokenParser 数值解析器
 type NumTokenParser struct {
 	AToken
@@ -291,29 +316,32 @@ type OrTree struct {
 }
 
 // NewOrTree 创建Or逻辑解析器元素
-func NewOrTree(parsers []*Parser) OrTree {
-	return OrTree{parsers: parsers}
+func NewOrTree(parsers []*Parser) *OrTree {
+	return &OrTree{parsers: parsers}
 }
 
 // Parse Or逻辑解析
-func (o OrTree) Parse(lexer *Lexer, res *list.ArrayList) {
+func (o *OrTree) Parse(lexer *Lexer, res *list.ArrayList) {
 	p := o.choose(lexer)
 	if p == nil {
-		t, _ := lexer.Peek(0)
-		panic(fmt.Sprintf("解析错误: %v\n", t))
+		t, err := lexer.Peek(0)
+		if err != nil {
+			panic(err)
+		}
+		ParserError("", t)
 	} else {
 		res.Add(p.parse(lexer))
 	}
 }
 
 // Match or逻辑匹配
-func (o OrTree) Match(lexer *Lexer) bool {
+func (o *OrTree) Match(lexer *Lexer) bool {
 	p := o.choose(lexer)
 	return p != nil
 }
 
 // choose
-func (o OrTree) choose(lexer *Lexer) *Parser {
+func (o *OrTree) choose(lexer *Lexer) *Parser {
 	for _, p := range o.parsers {
 		if p.Match(lexer) {
 			return p
@@ -322,13 +350,84 @@ func (o OrTree) choose(lexer *Lexer) *Parser {
 	return nil
 }
 
-// insert
-func (o OrTree) insert(p *Parser) {
+// insert 在最前面插入解析器
+func (o *OrTree) insert(p *Parser) {
 	ps := make([]*Parser, 1)
 	ps[0] = p
 	o.parsers = append(ps, o.parsers...)
 }
 
+// RecoverParser 错误恢复元素, 开启错误恢复时记录语法错误并跳过至同步单词
+type RecoverParser struct {
+	parser *Parser
+	sync   mapset.Set // 同步单词(语句边界)
+}
+
+// NewRecoverParser 创建RecoverParser
+func NewRecoverParser(parser *Parser, sync mapset.Set) RecoverParser {
+	return RecoverParser{parser, sync}
+}
+
+// Parse 解析, 出错时生成ErrorNode
+func (r RecoverParser) Parse(lexer *Lexer, res *list.ArrayList) {
+	if !lexer.recover {
+		res.Add(r.parser.parse(lexer))
+		return
+	}
+	res.Add(r.parseOrRecover(lexer))
+}
+
+// parseOrRecover 解析, 出错时记录错误并跳过至同步单词(不读取同步单词)
+func (r RecoverParser) parseOrRecover(lexer *Lexer) (node TreeNode) {
+	defer func() {
+		if e := recover(); e != nil {
+			err, ok := e.(*SyntaxError)
+			if !ok {
+				panic(e)
+			}
+			lexer.addError(err)
+			SkipTo(lexer, r.sync)
+			node = NewErrorNode(err)
+		}
+	}()
+	return r.parser.parse(lexer)
+}
+
+// Match 匹配, 开启错误恢复时除同步单词外的任意单词均视为匹配
+func (r RecoverParser) Match(lexer *Lexer) bool {
+	if r.parser.Match(lexer) {
+		return true
+	}
+	if !lexer.recover {
+		return false
+	}
+	t, err := lexer.Peek(0)
+	if err != nil {
+		panic(err)
+	}
+	return !isSync(t, r.sync)
+}
+
+// SkipTo 跳过单词直到同步单词或结尾, 期间的词法错误会被记录
+func SkipTo(lexer *Lexer, sync mapset.Set) {
+	for {
+		t, err := lexer.Peek(0)
+		if err != nil {
+			lexer.addError(err.(*SyntaxError))
+			continue
+		}
+		if isSync(t, sync) {
+			return
+		}
+		lexer.Read()
+	}
+}
+
+// isSync 是否为同步单词或结尾
+func isSync(t Token, sync mapset.Set) bool {
+	return t == EOF || (t.IsIdentifier() && sync.Contains(t.GetText()))
+}
+
 // RepeatParser
 type RepeatParser struct {
 	parser   *Parser
@@ -474,6 +573,12 @@ func (p *Parser) Or(parsers []*Parser) *Parser {
 	return p
 }
 
+// Token 匹配指定的单词并保留为叶子节点
+func (p *Parser) Token(pat ...string) *Parser {
+	p.elements.Add(NewLeaf(pat))
+	return p
+}
+
 // Sep
 func (p *Parser) Sep(pat ...string) *Parser {
 	p.elements.Add(NewSkip(pat))
@@ -498,6 +603,12 @@ func (p *Parser) Identifier(typ interface{}, r mapset.Set) *Parser {
 	return p
 }
 
+// Keyword 匹配指定的标识符, 以typ类型的叶子节点保留
+func (p *Parser) Keyword(typ interface{}, words ...string) *Parser {
+	p.elements.Add(NewKeywordParser(typ, words))
+	return p
+}
+
 // String
 func (p *Parser) String(typ interface{}) *Parser {
 	p.elements.Add(NewStrTokenParser(typ))
@@ -506,8 +617,9 @@ func (p *Parser) String(typ interface{}) *Parser {
 
 // Maybe
 func (p *Parser) Maybe(parser *Parser) *Parser {
+	// 保留parser的节点类型, 使不匹配时得到该类型的空节点
 	p2 := NewParserFromParser(parser)
-	p2.reset(nil)
+	p2.elements = list.New(10)
 	p.elements.Add(NewOrTree([]*Parser{parser, p2}))
 	return p
 }
@@ -524,6 +636,12 @@ func (p *Parser) Repeat(parser *Parser) *Parser {
 	return p
 }
 
+// Recover 开启错误恢复时, parser出错后跳过至sync中的单词继续解析
+func (p *Parser) Recover(parser *Parser, sync mapset.Set) *Parser {
+	p.elements.Add(NewRecoverParser(parser, sync))
+	return p
+}
+
 // Expression
 func (p *Parser) Expression(typ interface{}, exp *Parser, ops Operators) *Parser {
 	p.elements.Add(NewExprParser(typ, exp, ops))
@@ -535,8 +653,8 @@ func (p *Parser) InsertChoice(parser *Parser) *Parser {
 	item, _ := p.elements.Get(0)
 	e := item.(ParserElement)
 	switch e.(type) {
-	case OrTree:
-		e.(OrTree).insert(parser)
+	case *OrTree:
+		e.(*OrTree).insert(parser)
 		break
 	default:
 		otherwise := NewParserFromParser(p)
@@ -0,0 +1,152 @@
+package lexer
+
+import (
+	"fmt"
+	"reflect"
+	"unicode"
+	"unicode/utf8"
+)
+
+// AllowList 允许脚本访问的Go类型及其成员, 为nil时允许访问所有类型的导出成员
+type AllowList struct {
+	types map[reflect.Type]map[string]bool // 类型 -> 允许访问的成员, 包含""时允许所有导出成员
+}
+
+// NewAllowList 创建AllowList, 未添加的类型均不可访问
+func NewAllowList() *AllowList {
+	return &AllowList{types: make(map[reflect.Type]map[string]bool)}
+}
+
+// Allow 允许访问v的类型(或v为reflect.Type时该类型)的指定成员, 未指定成员时允许所有导出成员,
+// 指针与其指向的类型视为同一类型
+func (a *AllowList) Allow(v interface{}, members ...string) *AllowList {
+	t, ok := v.(reflect.Type)
+	if !ok {
+		t = reflect.TypeOf(v)
+	}
+	t = baseType(t)
+	set := a.types[t]
+	if set == nil {
+		set = make(map[string]bool)
+		a.types[t] = set
+	}
+	if len(members) == 0 {
+		set[""] = true
+	}
+	for _, m := range members {
+		set[m] = true
+	}
+	return a
+}
+
+// allowed 是否允许访问t类型的member成员, 非导出成员总是不可访问
+func (a *AllowList) allowed(t reflect.Type, member string) bool {
+	r, _ := utf8.DecodeRuneInString(member)
+	if !unicode.IsUpper(r) {
+		return false
+	}
+	if a == nil {
+		return true
+	}
+	set, ok := a.types[baseType(t)]
+	return ok && (set[""] || set[member])
+}
+
+// baseType 指针类型指向的类型
+func baseType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem()
+	}
+	return t
+}
+
+// GoObject Go结构体的代理, 脚本中以obj.Field读写导出字段, 以obj.Method(...)调用导出方法
+type GoObject struct {
+	value reflect.Value // 结构体或指向结构体的指针
+	allow *AllowList    // 允许访问的成员
+}
+
+// NewGoObject 创建v的代理, v须为结构体或指向结构体的指针,
+// 只有以指针创建或作为可寻址的值(如指针指向的结构体中的字段)时才能修改字段
+func NewGoObject(v interface{}, allow *AllowList) (*GoObject, error) {
+	rv := reflect.ValueOf(v)
+	if !isStruct(rv) {
+		return nil, fmt.Errorf("not a struct: %T", v)
+	}
+	return &GoObject{rv, allow}, nil
+}
+
+// ToValue 将Go值转换为脚本中的值, 结构体转换为*GoObject, 切片、数组与映射中的元素同样转换
+func ToValue(v interface{}, allow *AllowList) (Value, error) {
+	return fromGoValue(reflect.ValueOf(v), allow)
+}
+
+// Interface 获取代理的Go值
+func (g *GoObject) Interface() interface{} {
+	return g.value.Interface()
+}
+
+// String String方法
+func (g *GoObject) String() string {
+	if s, ok := g.value.Interface().(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("<go:%v>", g.value.Type())
+}
+
+// Read 读取字段或获取绑定到该对象的方法
+func (g *GoObject) Read(member string) (Value, error) {
+	if !g.allow.allowed(g.value.Type(), member) {
+		return nil, fmt.Errorf("cannot access %v.%v", baseType(g.value.Type()), member)
+	}
+	if m := g.value.MethodByName(member); m.IsValid() {
+		return newGoFunction(member, m, g.allow), nil
+	}
+	if f := g.field(member); f.IsValid() {
+		return fromGoValue(f, g.allow)
+	}
+	return nil, fmt.Errorf("%v has no member %v", baseType(g.value.Type()), member)
+}
+
+// Write 设置字段, 值转换为字段的类型
+func (g *GoObject) Write(member string, value Value) error {
+	if !g.allow.allowed(g.value.Type(), member) {
+		return fmt.Errorf("cannot access %v.%v", baseType(g.value.Type()), member)
+	}
+	f := g.field(member)
+	if !f.IsValid() {
+		return fmt.Errorf("%v has no field %v", baseType(g.value.Type()), member)
+	}
+	if !f.CanSet() {
+		return fmt.Errorf("cannot assign to %v.%v", baseType(g.value.Type()), member)
+	}
+	v, err := toGoValue(value, f.Type())
+	if err != nil {
+		return err
+	}
+	f.Set(v)
+	return nil
+}
+
+// field 获取字段, 不存在或指针为nil时返回无效的值
+func (g *GoObject) field(name string) reflect.Value {
+	s := g.value
+	if s.Kind() == reflect.Ptr {
+		if s.IsNil() {
+			return reflect.Value{}
+		}
+		s = s.Elem()
+	}
+	return s.FieldByName(name)
+}
+
+// isStruct 是否为结构体或指向结构体的非nil指针
+func isStruct(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Struct:
+		return true
+	case reflect.Ptr:
+		return !v.IsNil() && v.Elem().Kind() == reflect.Struct
+	}
+	return false
+}
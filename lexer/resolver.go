@@ -0,0 +1,488 @@
+package lexer
+
+import (
+	"fmt"
+	"sort"
+
+	"simple-script-language/utils/list"
+)
+
+// Resolve 在执行前解析语句中的变量, 为函数和代码块中的变量分配帧中的下标, 全局变量仍按名称保存在env中。
+// 函数的参数、var(在整个函数中可见)、函数体中直接出现的赋值语句(外层没有同名变量时)定义函数的局部变量,
+// let、const、def、class定义所在代码块的变量, 顶层的这些语句定义全局变量; 其他位置的赋值只能修改已定义的变量。
+// 定义前使用变量、为未定义的变量或常量赋值、在同一作用域中重复定义let和const时以ErrorList返回所有错误
+func Resolve(nodes []TreeNode, env Environment) error {
+	r := &resolver{env: env}
+	r.global = &scope{declared: make(map[string]binding), defined: make(map[string]bool), function: true}
+	r.scope = r.global
+	top := list.New(len(nodes))
+	for _, node := range nodes {
+		top.Add(node)
+	}
+	r.declareAll(r.global, NewBlockStatementNode(top), true)
+	for _, node := range nodes {
+		r.statement(node)
+	}
+	if len(r.errors) > 0 {
+		return r.errors
+	}
+	return nil
+}
+
+// binding 变量的定义方式
+type binding int
+
+const (
+	bindImplicit binding = iota // 赋值语句
+	bindParam                   // 参数
+	bindDef                     // def、class
+	bindVar                     // var
+	bindLet                     // let
+	bindConst                   // const
+)
+
+// scope 解析时的作用域
+type scope struct {
+	parent   *scope
+	layout   *layout            // 帧中的变量布局, 全局及类体为nil
+	declared map[string]binding // 作用域中定义的变量
+	defined  map[string]bool    // 已解析到定义处的变量
+	function bool               // 函数或顶层, 否则为代码块
+	class    bool               // 类体, 其中的字段和方法只能按名称查找
+	dynamic  bool               // 位于类体中的函数, 赋值可能修改字段, 赋值语句不定义变量
+}
+
+// resolver 解析器
+type resolver struct {
+	env    Environment // 全局环境
+	global *scope      // 顶层作用域
+	scope  *scope      // 当前作用域
+	errors ErrorList
+}
+
+// errorf 记录错误
+func (r *resolver) errorf(node TreeNode, format string, a ...interface{}) {
+	span := node.Span()
+	r.errors = append(r.errors, &SyntaxError{
+		Position: span.Start,
+		End:      span.End,
+		Token:    firstToken(node),
+		Msg:      fmt.Sprintf(format, a...),
+	})
+}
+
+// declare 在作用域中定义变量, let和const不能与同一作用域中的其他定义重名
+func (r *resolver) declare(s *scope, name string, kind binding, node TreeNode) {
+	old, ok := s.declared[name]
+	if ok && (kind == bindLet || kind == bindConst || old == bindLet || old == bindConst) {
+		r.errorf(node, "name already declared: %v", name)
+		return
+	}
+	if !ok || old == bindImplicit {
+		s.declared[name] = kind
+	}
+}
+
+// declareAll 收集代码块中定义的变量, 函数体及顶层还包括其中任意位置的var和直接出现的赋值语句
+func (r *resolver) declareAll(s *scope, block TreeNode, function bool) {
+	block.Children().For(func(k int, v interface{}) {
+		switch n := v.(type) {
+		case DeclStatementNode:
+			if n.Kind() != "var" {
+				r.declare(s, n.Name(), n.binding(), n)
+			}
+		case DefStatementNode:
+			r.declare(s, n.Name(), bindDef, n)
+		case ClassStatementNode:
+			r.declare(s, n.Name(), bindDef, n)
+		}
+	})
+	if !function {
+		return
+	}
+	var walk func(n TreeNode)
+	walk = func(n TreeNode) {
+		switch v := n.(type) {
+		case FunNode, DefStatementNode, ClassBodyNode:
+			return
+		case DeclStatementNode:
+			if v.Kind() == "var" {
+				r.declare(s, v.Name(), bindVar, v)
+			}
+		}
+		n.Children().For(func(k int, child interface{}) {
+			walk(child.(TreeNode))
+		})
+	}
+	walk(block)
+	if s.dynamic {
+		return
+	}
+	// 赋值语句只在外层没有同名变量时定义变量, 否则修改外层的变量
+	block.Children().For(func(k int, v interface{}) {
+		if name, ok := declaration(v.(TreeNode)); ok {
+			if _, ok := s.declared[name]; !ok && (s == r.global || !r.visible(name)) {
+				s.declared[name] = bindImplicit
+			}
+		}
+	})
+}
+
+// declaration 是否为变量赋值语句, 是时返回变量名
+func declaration(node TreeNode) (string, bool) {
+	if b, ok := node.(BinaryExprNode); ok && b.Operator() == "=" {
+		if v, ok := b.Left().(VariableNode); ok {
+			return v.Name(), true
+		}
+	}
+	return "", false
+}
+
+// visible 变量是否已在当前作用域或外层作用域中定义
+func (r *resolver) visible(name string) bool {
+	for s := r.scope; s != nil; s = s.parent {
+		if s.class {
+			return true
+		}
+		if _, ok := s.declared[name]; ok {
+			return true
+		}
+	}
+	_, ok := r.env.Get(name)
+	return ok
+}
+
+// statement 解析函数体或顶层中直接出现的语句, 定义变量的赋值语句在赋值后才算作已定义
+func (r *resolver) statement(node TreeNode) {
+	name, ok := declaration(node)
+	kind, declared := r.scope.declared[name]
+	if !ok || !declared || kind != bindImplicit || r.scope.dynamic {
+		r.resolve(node)
+		return
+	}
+	b := node.(BinaryExprNode)
+	r.resolve(b.Right())
+	r.scope.defined[name] = true
+	r.assign(b.Left().(VariableNode))
+}
+
+// allocate 按名称顺序为作用域中的变量分配下标, 参数已按顺序占用前几个位置
+func (s *scope) allocate() {
+	names := make([]string, 0, len(s.declared))
+	for name, kind := range s.declared {
+		if kind != bindParam {
+			names = append(names, name)
+		}
+		if kind == bindConst {
+			s.layout.consts[name] = true
+		}
+	}
+	// 按名称排序, 使重复解析时下标不变
+	sort.Strings(names)
+	for _, name := range names {
+		s.layout.add(name)
+	}
+}
+
+// function 解析函数, 参数和函数体中定义的变量保存在函数的帧中
+func (r *resolver) function(params ParameterListNode, body BlockStatementNode) {
+	l := body.layout
+	l.reset()
+	l.function = true
+	s := &scope{
+		parent:   r.scope,
+		layout:   l,
+		declared: make(map[string]binding),
+		defined:  make(map[string]bool),
+		function: true,
+		dynamic:  r.scope.class || r.scope.dynamic,
+	}
+	for i := 0; i < params.Size(); i++ {
+		r.declare(s, params.Name(i), bindParam, params)
+		l.add(params.Name(i))
+		s.defined[params.Name(i)] = true
+	}
+	r.declareAll(s, body, true)
+	s.allocate()
+	outer := r.scope
+	r.scope = s
+	body.Children().For(func(k int, v interface{}) {
+		r.statement(v.(TreeNode))
+	})
+	r.scope = outer
+}
+
+// push 进入代码块的作用域, 其中的变量保存在布局为l的帧中
+func (r *resolver) push(l *layout) *scope {
+	l.reset()
+	r.scope = &scope{
+		parent:   r.scope,
+		layout:   l,
+		declared: make(map[string]binding),
+		defined:  make(map[string]bool),
+		dynamic:  r.scope.dynamic,
+	}
+	return r.scope
+}
+
+// pop 离开代码块的作用域, 其中没有定义变量时执行时不创建帧
+func (r *resolver) pop(s *scope) {
+	if len(s.declared) == 0 {
+		s.layout.resolved = false
+	}
+	r.scope = s.parent
+}
+
+// block 解析代码块, 其中定义了变量时执行代码块时创建新的帧
+func (r *resolver) block(body BlockStatementNode) {
+	s := r.push(body.layout)
+	r.declareAll(s, body, false)
+	if len(s.declared) == 0 {
+		// 没有定义变量时不占用帧, 其中的变量地址按外层计算
+		r.pop(s)
+		r.resolveChildren(body)
+		return
+	}
+	s.allocate()
+	r.resolveChildren(body)
+	r.pop(s)
+}
+
+// forIn 解析for-in循环, 循环变量定义在每次迭代新建的帧中
+func (r *resolver) forIn(n ForInStatementNode) {
+	r.resolve(n.Iterable())
+	s := r.push(n.layout)
+	s.declared[n.Name()] = bindLet
+	s.defined[n.Name()] = true
+	s.allocate()
+	r.resolve(n.Body())
+	r.pop(s)
+}
+
+// catch 解析catch部分, 错误变量只在catch代码块中可见
+func (r *resolver) catch(n CatchClauseNode) {
+	if n.Empty() {
+		return
+	}
+	s := r.push(n.layout)
+	s.declared[n.Name()] = bindLet
+	s.defined[n.Name()] = true
+	s.allocate()
+	r.resolve(n.Body())
+	r.pop(s)
+}
+
+// forLoop 解析C风格for循环, 初始化部分以let、const定义的变量只在循环中可见
+func (r *resolver) forLoop(n ForStatementNode) {
+	s := r.push(n.layout)
+	if d, ok := n.Init().(DeclStatementNode); ok && d.Kind() != "var" {
+		r.declare(s, d.Name(), d.binding(), d)
+	}
+	if len(s.declared) == 0 {
+		r.pop(s)
+	} else {
+		s.allocate()
+	}
+	for _, node := range []TreeNode{n.Init(), n.Condition(), n.Step(), n.Body()} {
+		if node != nil {
+			r.resolve(node)
+		}
+	}
+	if r.scope == s {
+		r.pop(s)
+	}
+}
+
+// class 解析类体, 类体中的名称均按名称查找
+func (r *resolver) class(body ClassBodyNode) {
+	outer := r.scope
+	r.scope = &scope{parent: outer, class: true, declared: make(map[string]binding), defined: make(map[string]bool)}
+	body.Children().For(func(k int, v interface{}) {
+		switch member := v.(type) {
+		case DefStatementNode:
+			r.function(member.Parameters(), member.Body())
+		case BinaryExprNode:
+			if _, ok := declaration(member); ok {
+				r.resolve(member.Right())
+				member.Left().(VariableNode).addr.kind = addrDynamic
+				return
+			}
+			r.resolve(member)
+		default:
+			r.resolve(member.(TreeNode))
+		}
+	})
+	r.scope = outer
+}
+
+// resolveChildren 解析全部子节点
+func (r *resolver) resolveChildren(node TreeNode) {
+	node.Children().For(func(k int, child interface{}) {
+		r.resolve(child.(TreeNode))
+	})
+}
+
+// resolve 解析表达式或语句
+func (r *resolver) resolve(node TreeNode) {
+	switch n := node.(type) {
+	case VariableNode:
+		r.read(n)
+		return
+	case BinaryExprNode:
+		if n.Operator() != "=" {
+			break
+		}
+		r.resolve(n.Right())
+		if v, ok := n.Left().(VariableNode); ok {
+			r.assign(v)
+		} else {
+			r.resolve(n.Left())
+		}
+		return
+	case BlockStatementNode:
+		r.block(n)
+		return
+	case DeclStatementNode:
+		r.declaration(n)
+		return
+	case ForInStatementNode:
+		r.forIn(n)
+		return
+	case ForStatementNode:
+		r.forLoop(n)
+		return
+	case CatchClauseNode:
+		r.catch(n)
+		return
+	case DefStatementNode:
+		r.scope.defined[n.Name()] = true
+		r.function(n.Parameters(), n.Body())
+		return
+	case FunNode:
+		r.function(n.Parameters(), n.Body())
+		return
+	case ClassStatementNode:
+		if super := n.SuperClass(); super != "" {
+			node, _ := n.Child(1)
+			r.check(node, super)
+		}
+		r.scope.defined[n.Name()] = true
+		r.class(n.Body())
+		return
+	case DotNode, ParameterListNode, ErrorNode:
+		return
+	}
+	r.resolveChildren(node)
+}
+
+// declaration 解析var、let、const, var定义在所在函数或顶层的作用域中
+func (r *resolver) declaration(n DeclStatementNode) {
+	if init := n.Initializer(); init != nil {
+		r.resolve(init)
+	} else if n.Kind() == "const" {
+		r.errorf(n, "missing initializer for const: %v", n.Name())
+	}
+	if r.scope.class {
+		// 类体中的声明定义对象的字段
+		*n.addr = address{kind: addrDynamic}
+		return
+	}
+	s, depth := r.scope, 0
+	for n.Kind() == "var" && !s.function {
+		depth++
+		s = s.parent
+	}
+	s.defined[n.Name()] = true
+	if s.layout == nil {
+		*n.addr = address{kind: addrGlobal, depth: depth}
+		return
+	}
+	*n.addr = address{kind: addrLocal, depth: depth, slot: s.layout.slots[n.Name()]}
+}
+
+// lookup 查找变量的地址及其所在的作用域, crossed为true时变量定义在外层函数中
+func (r *resolver) lookup(name string) (addr address, found *scope, crossed bool) {
+	depth := 0
+	for s := r.scope; s != nil; s = s.parent {
+		if s.class {
+			return address{kind: addrDynamic}, nil, crossed
+		}
+		if s.layout == nil {
+			return address{kind: addrGlobal, depth: depth}, s, crossed
+		}
+		if slot, ok := s.layout.slots[name]; ok {
+			return address{kind: addrLocal, depth: depth, slot: slot}, s, crossed
+		}
+		depth++
+		if s.function {
+			crossed = true
+		}
+	}
+	return address{kind: addrGlobal, depth: depth}, nil, crossed
+}
+
+// inFunction 当前是否位于函数或类体中
+func (r *resolver) inFunction() bool {
+	for s := r.scope; s != nil; s = s.parent {
+		if s.function || s.class {
+			return s != r.global
+		}
+	}
+	return false
+}
+
+// read 解析读取的变量
+func (r *resolver) read(v VariableNode) {
+	addr, s, crossed := r.lookup(v.Name())
+	*v.addr = addr
+	switch addr.kind {
+	case addrLocal:
+		if !crossed && !s.defined[v.Name()] {
+			r.errorf(v, "name used before definition: %v", v.Name())
+		}
+	case addrGlobal:
+		r.check(v, v.Name())
+	}
+}
+
+// check 检查顶层中读取的全局变量是否已定义, 函数中的全局变量在调用时才读取, 不作检查
+func (r *resolver) check(node TreeNode, name string) {
+	if r.inFunction() || r.global.defined[name] {
+		return
+	}
+	if _, ok := r.env.Get(name); ok {
+		return
+	}
+	if _, ok := r.global.declared[name]; ok {
+		r.errorf(node, "name used before definition: %v", name)
+	} else {
+		r.errorf(node, "undefined name: %v", name)
+	}
+}
+
+// assign 解析赋值的变量
+func (r *resolver) assign(v VariableNode) {
+	name := v.Name()
+	addr, s, crossed := r.lookup(name)
+	*v.addr = addr
+	switch addr.kind {
+	case addrLocal:
+		if !crossed && !s.defined[name] {
+			r.errorf(v, "name used before definition: %v", name)
+		} else if s.declared[name] == bindConst {
+			r.errorf(v, "cannot assign to constant: %v", name)
+		}
+	case addrGlobal:
+		kind, declared := r.global.declared[name]
+		_, exists := r.env.Get(name)
+		switch {
+		case kind == bindConst || (!declared || kind == bindImplicit) && exists && isConst(r.env, name):
+			r.errorf(v, "cannot assign to constant: %v", name)
+		case exists || declared && (r.inFunction() || r.global.defined[name]):
+		case declared:
+			r.errorf(v, "name used before definition: %v", name)
+		default:
+			r.errorf(v, "assignment to undeclared name: %v", name)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package lexer
+
+import (
+	"strings"
+	"testing"
+)
+
+// resolve 解析并以空的全局环境解析变量
+func resolve(t *testing.T, src string) ([]TreeNode, error) {
+	t.Helper()
+	nodes, err := Parse("test.ssl", strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("%q: %v", src, err)
+	}
+	return nodes, Resolve(nodes, NewNestedEnvironment(NewBuiltinEnv(nil)))
+}
+
+func TestResolveDiagnostics(t *testing.T) {
+	tests := []struct {
+		src string
+		err string // 为空时应当解析成功
+	}{
+		// 顶层的赋值语句定义全局变量
+		{"i = 0\nprintln(i)", ""},
+		{"i = 0\ni = i + 1", ""},
+		{"println(x)\nx = 1", "name used before definition: x"},
+		{"println(y)", "undefined name: y"},
+		// 只有直接出现的赋值语句定义变量, 表达式中及for初始化部分的赋值不定义变量
+		{"a = b = 3", "assignment to undeclared name: b"},
+		{"for (i = 0; i < 3; i = i + 1) {}", "assignment to undeclared name: i"},
+		{"for (let i = 0; i < 3; i = i + 1) {}", ""},
+		{"i = 0\nfor (i = 0; i < 3; i = i + 1) {}", ""},
+		{"if true { z = 1 }", "assignment to undeclared name: z"},
+		// 函数中的赋值定义局部变量, 外层有同名变量时修改外层变量
+		{"def f() { n = 1\nn }", ""},
+		{"def f() { println(n)\nn = 1 }", "name used before definition: n"},
+		{"def f() { if true { n = 1 } }", "assignment to undeclared name: n"},
+		{"n = 0\ndef f() { if true { n = 1 } }", ""},
+		{"def f() { g() }\ndef g() { 1 }", ""},
+		{"def f() { missing }", ""},
+		// var在整个函数中可见, let和const只在代码块中可见
+		{"def f() { if true { var v = 1 }\nv }", ""},
+		{"def f() { if true { let v = 1 }\nv }", ""},
+		{"let v = 1\nlet v = 2", "name already declared: v"},
+		{"const c = 1\nc = 2", "cannot assign to constant: c"},
+		{"const c", "missing initializer for const: c"},
+		{"def f() { const c = 1\nc = 2 }", "cannot assign to constant: c"},
+		{"def f() { let v = v }", "name used before definition: v"},
+		// 闭包中读取外层函数稍后定义的变量时不报错
+		{"def f() { g = fun() { n }\nn = 1\ng() }", ""},
+		{"class A extends B {}", "undefined name: B"},
+		{"class A { x = 1\ndef get() { x = x + 1 } }", ""},
+	}
+	for _, tt := range tests {
+		_, err := resolve(t, tt.src)
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%q: unexpected error: %v", tt.src, err)
+		case tt.err != "" && err == nil:
+			t.Errorf("%q: expected error %q", tt.src, tt.err)
+		case tt.err != "" && !strings.Contains(err.Error(), tt.err):
+			t.Errorf("%q: got %v, want %q", tt.src, err, tt.err)
+		}
+	}
+}
+
+// variables 按出现顺序获取语法树中的变量
+func variables(node TreeNode, result *[]VariableNode) {
+	if v, ok := node.(VariableNode); ok {
+		*result = append(*result, v)
+		return
+	}
+	node.Children().For(func(k int, child interface{}) {
+		variables(child.(TreeNode), result)
+	})
+}
+
+func TestResolveAddresses(t *testing.T) {
+	src := `g = 1
+def f(a, b) {
+  c = a + g
+  if true {
+    let d = c
+    fun() { d + b + c }
+  }
+}`
+	nodes, err := resolve(t, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vars []VariableNode
+	for _, node := range nodes {
+		variables(node, &vars)
+	}
+	// 函数f的帧中参数a、b占用前两个位置, 局部变量c在其后; 代码块的帧中只有d
+	want := []struct {
+		name string
+		addr address
+	}{
+		{"g", address{kind: addrGlobal}},
+		{"c", address{kind: addrLocal, slot: 2}},
+		{"a", address{kind: addrLocal, slot: 0}},
+		{"g", address{kind: addrGlobal, depth: 1}},
+		{"c", address{kind: addrLocal, depth: 1, slot: 2}},
+		{"d", address{kind: addrLocal, depth: 1, slot: 0}},
+		{"b", address{kind: addrLocal, depth: 2, slot: 1}},
+		{"c", address{kind: addrLocal, depth: 2, slot: 2}},
+	}
+	if len(vars) != len(want) {
+		t.Fatalf("got %v variables, want %v", len(vars), len(want))
+	}
+	for i, w := range want {
+		if vars[i].Name() != w.name || *vars[i].addr != w.addr {
+			t.Errorf("variable %v: got %v %+v, want %v %+v", i, vars[i].Name(), *vars[i].addr, w.name, w.addr)
+		}
+	}
+}
+
+func TestResolveClassMembersAreDynamic(t *testing.T) {
+	nodes, err := resolve(t, "class A { x = 1\ndef get() { x } }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vars []VariableNode
+	variables(nodes[0], &vars)
+	for _, v := range vars {
+		if v.addr.kind != addrDynamic {
+			t.Errorf("%v: got %+v, want dynamic", v.Name(), *v.addr)
+		}
+	}
+}
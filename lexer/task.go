@@ -0,0 +1,206 @@
+package lexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"simple-script-language/utils/list"
+	"sync"
+	"sync/atomic"
+)
+
+// Task spawn创建的任务, 函数在单独的goroutine中执行, 由内置函数wait获取结果
+type Task struct {
+	name   string
+	done   chan struct{} // 任务结束时关闭
+	result interface{}
+	err    error
+	waited int32 // 是否已被等待, 以原子操作访问
+}
+
+// String 实现String接口
+func (t *Task) String() string {
+	return fmt.Sprintf("<task:%v:%p>", t.name, t)
+}
+
+// TypeName 类型名
+func (t *Task) TypeName() string {
+	return "task"
+}
+
+// Done 任务是否已结束
+func (t *Task) Done() bool {
+	select {
+	case <-t.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// wait 等待任务结束, 返回函数的返回值及运行时错误; 执行状态th被取消时在node处返回LimitExceeded
+func (t *Task) wait(th *thread, node TreeNode) (Value, error) {
+	select {
+	case <-t.done:
+		atomic.StoreInt32(&t.waited, 1)
+		return t.result, t.err
+	case <-th.done:
+		return nil, newCanceledError(node, th.ctx.Err())
+	}
+}
+
+// taskSet spawn创建的任务的集合, 任务在ctx取消时停止; 由finish取消并等待全部任务
+type taskSet struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	failed []*Task // 出错的任务
+}
+
+// newTaskSet 创建ctx取消时停止的任务集合
+func newTaskSet(ctx context.Context) *taskSet {
+	ctx, cancel := context.WithCancel(ctx)
+	return &taskSet{ctx: ctx, cancel: cancel}
+}
+
+// spawn 在新的goroutine中以args调用fn, 新线程与t共享执行限制的计数, 在t所属的任务集合取消时停止;
+// fn引用的环境及args在启动任务前以escape标记, 任务的结果在任务结束前标记, 因此其中的数组此后加锁访问
+func (t *thread) spawn(site TreeNode, fn interface{}, args []Value) *Task {
+	var name string
+	switch f := fn.(type) {
+	case *Function:
+		f.checkArity(site, args)
+		name = f.Name()
+	case *NativeFunction:
+		name = f.Name()
+	default:
+		panic(NewTypeError(site, "bad function"))
+	}
+	escape(fn)
+	for _, arg := range args {
+		escape(arg)
+	}
+	task := &Task{name: name, done: make(chan struct{})}
+	tasks := t.tasks
+	child := &thread{group: t.group, ctx: tasks.ctx, done: tasks.ctx.Done(), root: t.function()}
+	tasks.wg.Add(1)
+	go func() {
+		defer tasks.wg.Done()
+		defer close(task.done)
+		defer func() {
+			if r := recover(); r != nil {
+				child.unwind(r, 0)
+				task.err = RecoveredError(r)
+				var te *ThrowError
+				if errors.As(task.err, &te) {
+					escape(te.Value)
+				}
+				tasks.fail(task)
+			}
+		}()
+		if f, ok := fn.(*Function); ok {
+			task.result = f.run(child, site, args)
+		} else {
+			task.result = fn.(*NativeFunction).run(child, site, args)
+		}
+		escape(task.result)
+	}()
+	return task
+}
+
+// fail 记录出错的任务
+func (s *taskSet) fail(task *Task) {
+	s.mu.Lock()
+	s.failed = append(s.failed, task)
+	s.mu.Unlock()
+}
+
+// finish 取消尚未结束的任务并等待其退出, 返回最先出错且未被等待的任务的错误, 因取消而停止的任务不算出错
+func (s *taskSet) finish() error {
+	s.cancel()
+	s.wg.Wait()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, task := range s.failed {
+		if atomic.LoadInt32(&task.waited) == 0 && !errors.Is(task.err, context.Canceled) {
+			return task.err
+		}
+	}
+	return nil
+}
+
+// SpawnNode spawn表达式, 如spawn f(x)或go f(x), 在新的goroutine中调用函数并返回任务; 第一个子节点为关键字
+type SpawnNode struct {
+	BranchNode
+}
+
+// NewSpawnNode 创建SpawnNode
+func NewSpawnNode(list *list.ArrayList) SpawnNode {
+	return SpawnNode{NewBranchNode(list)}
+}
+
+// Keyword 关键字spawn或go
+func (s SpawnNode) Keyword() string {
+	n, _ := s.Child(0)
+	return n.(LeafNode).token.GetText()
+}
+
+// Call 函数调用表达式, 不是函数调用时返回false
+func (s SpawnNode) Call() (PrimaryExpr, bool) {
+	n, _ := s.Child(1)
+	p, ok := n.(PrimaryExpr)
+	if !ok || !p.HasPostfix(0) {
+		return p, false
+	}
+	_, ok = p.Postfix(0).(ArgumentsNode)
+	return p, ok
+}
+
+// String 实现String接口
+func (s SpawnNode) String() string {
+	n, _ := s.Child(1)
+	return fmt.Sprintf("(%v %v)", s.Keyword(), n)
+}
+
+// Eval 在当前goroutine中计算函数及实参, 然后在新的goroutine中调用, 返回*Task
+func (s SpawnNode) Eval(env Environment) interface{} {
+	call, _ := s.Call()
+	fn := call.EvalSubExpr(env, 1)
+	arguments := call.Postfix(0).(ArgumentsNode)
+	args := make([]Value, 0, arguments.Size())
+	arguments.Children().For(func(k int, v interface{}) {
+		args = append(args, v.(TreeNode).Eval(env))
+	})
+	return currentThread(env).spawn(call, fn, args)
+}
+
+// builtinWait 等待任务结束wait(task, ...), 返回任务的结果, 多个任务或一个任务数组时以数组返回各个结果;
+// 任务出错时抛出任务中的错误
+func builtinWait(t *thread, node TreeNode, args []Value) (Value, error) {
+	tasks := args
+	array, isArray := args[0].(*list.ArrayList)
+	if isArray && len(args) == 1 {
+		tasks = make([]Value, 0, array.Size())
+		array.For(func(k int, v interface{}) {
+			tasks = append(tasks, v)
+		})
+	}
+	results := list.New(len(tasks))
+	for i, v := range tasks {
+		task, ok := v.(*Task)
+		if !ok {
+			return nil, badArgument(tasks, i, "task")
+		}
+		result, err := task.wait(t, node)
+		if err != nil {
+			return nil, err
+		}
+		results.Add(result)
+	}
+	if len(args) == 1 && !isArray {
+		v, _ := results.Get(0)
+		return v, nil
+	}
+	return results, nil
+}
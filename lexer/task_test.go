@@ -0,0 +1,177 @@
+package lexer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConcurrency(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"wait", `
+def square(n) { n * n }
+t = spawn square(3)
+println(wait(t), wait(go square(4), go square(5)), wait([spawn square(6)]))`,
+			"9 [16, 25] [36]\n"},
+		{"channel", `
+ch = channel()
+spawn fun() {
+  for (let i = 0; i < 5; i = i + 1) { ch.send(i) }
+  ch.close()
+}()
+sum = 0
+for v in ch { sum = sum + v }
+println(sum, ch.recv())`,
+			"10 nil\n"},
+		{"select", `
+a = channel(1)
+b = channel(1)
+println(select(a, nil))
+b.send(2)
+println(select(a, b))
+println(select([a, 3])[0], a.recv())`,
+			"[1, nil, false]\n[1, 2, true]\n0 3\n"},
+		// 多个任务同时读写共享的变量、映射和数组, 以容量为1的通道作为锁保证计数正确
+		{"shared", `
+n = 0
+m = {0: 0, 1: 0, 2: 0, 3: 0}
+a = [0, 0, 0, 0]
+lock = channel(1)
+def work(id) {
+  for (let i = 0; i < 50; i = i + 1) {
+    lock.send(1)
+    n = n + 1
+    lock.recv()
+    m[id] = i
+    a[id] = a[id] + 1
+    a[0] = a[0] + 0
+  }
+}
+wait([spawn work(0), spawn work(1), spawn work(2), spawn work(3)])
+println(n, m, a)`,
+			"200 {0: 49, 1: 49, 2: 49, 3: 49} [50, 50, 50, 50]\n"},
+		// 任务开始后才保存到全局变量或通过通道传入任务的数组同样加锁访问
+		{"escaped", `
+g = nil
+ch = channel()
+def work() {
+  let a = ch.recv()
+  for (let i = 0; i < 50; i = i + 1) {
+    a[0] = a[0] + g[0] - g[0] + 1
+    g[1][0] = i
+  }
+  a[0]
+}
+t = spawn work()
+g = [0, [0]]
+m = {0: [0]}
+ch.send(m[0])
+s = 0
+for (let i = 0; i < 50; i = i + 1) {
+  g[0] = i
+  s = g[1][0] + m[0][0]
+}
+println(wait(t), m, g[1])`,
+			"50 {0: [50]} [49]\n"},
+		{"closure", `
+def counter() {
+  let count = 0
+  fun() { count = count + 1 }
+}
+c = counter()
+lock = channel(1)
+def work() {
+  for (let i = 0; i < 50; i = i + 1) { lock.send(1)
+    c()
+    lock.recv() }
+}
+wait(spawn work(), spawn work())
+println(c())`,
+			"101\n"},
+		{"object", `
+class Box { v = 0 }
+b = Box.new
+t = spawn fun() { b.v = 5 }()
+wait(t)
+println(b.v)`,
+			"5\n"},
+	}
+	for _, tt := range tests {
+		got, err := run(t, tt.src)
+		if err != nil {
+			t.Errorf("%v: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTaskErrors(t *testing.T) {
+	_, err := run(t, "t = spawn fun() { 1 / 0 }()\ntry { wait(t) } catch (e) { println(e) }")
+	if err != nil {
+		t.Errorf("waited error: %v", err)
+	}
+	// 未被等待的任务出错时由Close返回错误
+	interp := NewInterpreter(Config{})
+	if _, err := interp.Exec(context.Background(), "test.ssl", strings.NewReader("t = spawn fun() { 1 / 0 }()")); err != nil {
+		t.Fatal(err)
+	}
+	task, _ := interp.Get("t")
+	for !task.(*Task).Done() {
+		time.Sleep(time.Millisecond)
+	}
+	var zero *ZeroDivisionError
+	if err := interp.Close(); !errors.As(err, &zero) {
+		t.Errorf("unwaited error: got %v, want ZeroDivisionError", err)
+	}
+}
+
+func TestTaskCanceled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	nodes, err := Parse("test.ssl", strings.NewReader("ch = channel()\nt = spawn fun() { ch.recv() }()\nwait(t)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = EvalContext(ctx, nodes, NewNestedEnvironment(NewBuiltinEnv(nil)), Limits{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want deadline exceeded", err)
+	}
+}
+
+func TestInterpreterTasks(t *testing.T) {
+	interp := NewInterpreter(Config{})
+	exec := func(src string) Value {
+		t.Helper()
+		v, err := interp.Exec(context.Background(), "test.ssl", strings.NewReader(src))
+		if err != nil {
+			t.Fatalf("%q: %v", src, err)
+		}
+		return v
+	}
+	// 任务在多次执行之间继续运行
+	exec("ch = channel()\ndef w() { ch.recv() + 1 }\nt = spawn w()")
+	exec("ch.send(41)")
+	if v := exec("wait(t)"); v != 42 {
+		t.Errorf("got %v, want 42", v)
+	}
+	exec("blocked = spawn fun() { channel().recv() }()")
+	done := make(chan error)
+	go func() { done <- interp.Close() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("close: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not cancel the blocked task")
+	}
+}
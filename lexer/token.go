@@ -3,37 +3,48 @@ package lexer
 
 import (
 	"errors"
-	"strconv"
 )
 
 // Token 单词接口
 type Token interface {
 	GetLineNumber() int      // 获取行号
+	GetPosition() Position   // 获取起始位置
+	GetSpan() Span           // 获取所在区间
 	IsIdentifier() bool      // 是否为标识符(变量名、函数名、类名)
-	IsNumber() bool          // 是否为整型字面量
+	IsNumber() bool          // 是否为数值字面量
 	IsString() bool          // 是否为字符串字面量
-	GetNumber() (int, error) // 获取整型字面量的值
+	GetNumber() (int, error) // 获取整型字面量的值(不超出int范围时)
 	GetText() string         // 获取字符串字面量的值
 }
 
 var (
-	EOF = NewToken(1)
+	EOF = NewToken(Span{Start: Position{Line: -1}, End: Position{Line: -1}})
 	EOL = "\n"
 )
 
 // AbstractToken 词法分析的结果(单词)
 type AbstractToken struct {
-	lineNumber int //行号
+	span Span // 所在区间
 }
 
 // NewToken 创建一个新的Token
-func NewToken(line int) AbstractToken {
-	return AbstractToken{lineNumber: line}
+func NewToken(span Span) AbstractToken {
+	return AbstractToken{span: span}
 }
 
 // GetLineNumber 获取行号
 func (t AbstractToken) GetLineNumber() int {
-	return t.lineNumber
+	return t.span.Start.Line
+}
+
+// GetPosition 获取起始位置
+func (t AbstractToken) GetPosition() Position {
+	return t.span.Start
+}
+
+// GetSpan 获取所在区间
+func (t AbstractToken) GetSpan() Span {
+	return t.span
 }
 
 // IsIdentifier 是否为标识符(变量名、函数名、类名)
@@ -61,33 +72,43 @@ func (t AbstractToken) GetText() string {
 	return ""
 }
 
-// NumToken 整型字面量的Token
+// NumToken 数值字面量的Token
 type NumToken struct {
 	AbstractToken
-	value int // 整型字面量的值
+	text  string      // 字面量原文
+	value interface{} // 字面量的值, 为int、float64或超出int范围的*big.Int
 }
 
 // NewNumToken 创建NumToken对象
-func NewNumToken(line, value int) NumToken {
+func NewNumToken(span Span, text string, value interface{}) NumToken {
 	return NumToken{
-		AbstractToken: NewToken(line),
+		AbstractToken: NewToken(span),
+		text:          text,
 		value:         value,
 	}
 }
 
-// IsNumber 是否为整型字面量
+// IsNumber 是否为数值字面量
 func (n NumToken) IsNumber() bool {
 	return true
 }
 
-// GetText 获取字符串字面量的值
+// GetText 获取字面量原文
 func (n NumToken) GetText() string {
-	return strconv.Itoa(n.value)
+	return n.text
 }
 
 // GetNumber 获取整型字面量的值
 func (n NumToken) GetNumber() (int, error) {
-	return n.value, nil
+	if v, ok := n.value.(int); ok {
+		return v, nil
+	}
+	return -1, errors.New("not int token")
+}
+
+// Value 获取字面量的值
+func (n NumToken) Value() interface{} {
+	return n.value
 }
 
 // IdToken 标志符类型的Token
@@ -97,9 +118,9 @@ type IdToken struct {
 }
 
 // NewIdToken 创建IdToken对象
-func NewIdToken(line int, id string) IdToken {
+func NewIdToken(span Span, id string) IdToken {
 	return IdToken{
-		AbstractToken: NewToken(line),
+		AbstractToken: NewToken(span),
 		text:          id,
 	}
 }
@@ -121,9 +142,9 @@ type StrToken struct {
 }
 
 // NewStrToken 创建StrToken对象
-func NewStrToken(line int, literal string) StrToken {
+func NewStrToken(span Span, literal string) StrToken {
 	return StrToken{
-		AbstractToken: NewToken(line),
+		AbstractToken: NewToken(span),
 		literal:       literal,
 	}
 }
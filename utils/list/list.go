@@ -5,12 +5,16 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
 )
 
-// ArrayList 数组列表
+// ArrayList 数组列表, 以Share标记后可以在多个goroutine中同时访问
 type ArrayList struct {
-	list []interface{} // 内部数组
-	size int           // 长度
+	mu     sync.RWMutex
+	list   []interface{}          // 内部数组
+	size   int                    // 长度
+	shared bool                   // 是否加锁访问, 标记前只能在一个goroutine中访问
+	share  func(item interface{}) // 标记后添加或设置的元素先以该函数处理
 }
 
 // New 创建数组列表
@@ -21,17 +25,81 @@ func New(initLen int) *ArrayList {
 	}
 }
 
+// Share 标记数组可能在多个goroutine中访问, 此后加锁访问; 已有的元素及此后添加或设置的元素均以share处理,
+// 以便同时标记元素中的值。数组只在标记它的goroutine中标记, 被标记前其他goroutine无法访问该数组, 因此标记本身不需要加锁
+func (a *ArrayList) Share(share func(item interface{})) {
+	if a.shared {
+		return
+	}
+	a.shared, a.share = true, share
+	for k := 0; k < a.size; k++ {
+		share(a.list[k])
+	}
+}
+
+// lock 加写锁
+func (a *ArrayList) lock() {
+	if a.shared {
+		a.mu.Lock()
+	}
+}
+
+// unlock 释放写锁
+func (a *ArrayList) unlock() {
+	if a.shared {
+		a.mu.Unlock()
+	}
+}
+
+// rlock 加读锁
+func (a *ArrayList) rlock() {
+	if a.shared {
+		a.mu.RLock()
+	}
+}
+
+// runlock 释放读锁
+func (a *ArrayList) runlock() {
+	if a.shared {
+		a.mu.RUnlock()
+	}
+}
+
 // Get 获取指定索引的值
 func (a *ArrayList) Get(index int) (interface{}, error) {
-	if index < 0 || index > a.size {
-		return nil, errors.New(fmt.Sprintf("ArrayIndexOutOfBounds: size: %v, index: %v", a.size, index))
+	a.rlock()
+	if index < 0 || index >= a.size {
+		size := a.size
+		a.runlock()
+		return nil, errors.New(fmt.Sprintf("ArrayIndexOutOfBounds: size: %v, index: %v", size, index))
+	}
+	v := a.list[index]
+	a.runlock()
+	return v, nil
+}
+
+// Set 设置指定索引的值
+func (a *ArrayList) Set(index int, item interface{}) error {
+	if a.share != nil {
+		a.share(item)
+	}
+	a.lock()
+	defer a.unlock()
+	if index < 0 || index >= a.size {
+		return errors.New(fmt.Sprintf("ArrayIndexOutOfBounds: size: %v, index: %v", a.size, index))
 	}
-	return a.list[index], nil
+	a.list[index] = item
+	return nil
 }
 
 // add 添加
 func (a *ArrayList) Add(item interface{}) {
-	if a.size < len(a.list)-1 {
+	if a.share != nil {
+		a.share(item)
+	}
+	a.lock()
+	defer a.unlock()
+	if a.size < len(a.list) {
 		a.list[a.size] = item
 		a.size++
 		return
@@ -44,14 +112,16 @@ func (a *ArrayList) Add(item interface{}) {
 // expansion 数组扩容
 func expansion(list []interface{}) []interface{} {
 	len := len(list)
-	newList := make([]interface{}, len+(len)/2)
+	newList := make([]interface{}, len+(len)/2+1)
 	copy(newList, list)
 	return newList
 }
 
 // Remove 移除指定的数据
 func (a *ArrayList) Remove(index int) (interface{}, error) {
-	if index < 0 || index > a.size {
+	a.lock()
+	defer a.unlock()
+	if index < 0 || index >= a.size {
 		return nil, errors.New(fmt.Sprintf("ArrayIndexOutOfBounds: size: %v, index: %v", a.size, index))
 	}
 	listLen := len(a.list)
@@ -73,6 +143,8 @@ func (a *ArrayList) Remove(index int) (interface{}, error) {
 
 // Clear 清空
 func (a *ArrayList) Clear() {
+	a.lock()
+	defer a.unlock()
 	a.list = make([]interface{}, 10)
 	a.size = 0
 }
@@ -80,15 +152,9 @@ func (a *ArrayList) Clear() {
 // String
 func (a *ArrayList) String() string {
 	var buf bytes.Buffer
-	index := 0
-	for _, v := range a.list {
-		if index < a.size {
-			buf.WriteString(fmt.Sprintf("%v, ", v))
-			index++
-		} else {
-			break
-		}
-	}
+	a.For(func(k int, v interface{}) {
+		buf.WriteString(fmt.Sprintf("%v, ", v))
+	})
 	str := buf.String()
 	if strings.HasSuffix(str, ", ") {
 		str = str[0 : len(str)-2]
@@ -96,18 +162,25 @@ func (a *ArrayList) String() string {
 	return str
 }
 
-// For 循环
+// For 循环, 逐个读取元素, 不包括循环开始后添加的元素; 调用handler时不持有锁, 因此handler中可以修改该数组
 func (a *ArrayList) For(handler func(k int, v interface{})) {
-	for lk, lv := range a.list {
-		if lk < a.size {
-			handler(lk, lv)
-		} else {
-			break
+	size := a.Size()
+	for k := 0; k < size; k++ {
+		a.rlock()
+		if k >= a.size {
+			a.runlock()
+			return
 		}
+		v := a.list[k]
+		a.runlock()
+		handler(k, v)
 	}
 }
 
 // Size 大小
 func (a *ArrayList) Size() int {
-	return a.size
+	a.rlock()
+	size := a.size
+	a.runlock()
+	return size
 }
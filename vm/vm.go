@@ -0,0 +1,335 @@
+// Package vm 执行compiler包生成的字节码
+package vm
+
+import (
+	"context"
+	"fmt"
+
+	"simple-script-language/compiler"
+	"simple-script-language/lexer"
+	"simple-script-language/utils/list"
+)
+
+// cell 被闭包捕获的变量
+type cell struct {
+	value lexer.Value
+}
+
+// undefined 未赋值的局部变量
+type undefined struct{}
+
+// Closure 闭包, 函数原型及捕获的变量
+type Closure struct {
+	proto *compiler.Proto
+	free  []*cell
+}
+
+// Proto 获取函数原型
+func (c *Closure) Proto() *compiler.Proto {
+	return c.proto
+}
+
+// String 实现String接口
+func (c *Closure) String() string {
+	return fmt.Sprintf("<fun:%p>", c)
+}
+
+// TypeName 类型名称, 与解释执行的函数一致
+func (c *Closure) TypeName() string {
+	return "function"
+}
+
+// frame 函数调用的栈帧
+type frame struct {
+	closure *Closure
+	pc      int     // 下一条指令的位置
+	base    int     // 第一个局部变量在栈中的位置
+	cells   []*cell // 被捕获的局部变量
+}
+
+// VM 虚拟机
+type VM struct {
+	globals lexer.Environment
+	stack   []lexer.Value
+	frames  []frame
+	exec    *lexer.Execution // 当前执行的限制及取消
+	depth   int              // 最大调用深度
+}
+
+// New 创建以globals为全局环境的虚拟机
+func New(globals lexer.Environment) *VM {
+	return &VM{globals: globals}
+}
+
+// Run 执行顶层代码并返回最后一条语句的计算值, 只受默认的调用深度限制
+func (vm *VM) Run(proto *compiler.Proto) (lexer.Value, error) {
+	return vm.RunContext(context.Background(), proto, lexer.Limits{})
+}
+
+// RunContext 与Run相同, 执行受limits限制, ctx取消时停止执行; 循环的每次迭代及每次函数调用各为一步,
+// 超出限制或被取消时以*lexer.LimitExceeded返回
+func (vm *VM) RunContext(ctx context.Context, proto *compiler.Proto, limits lexer.Limits) (result lexer.Value, err error) {
+	vm.exec, vm.depth = lexer.NewExecution(ctx, limits), limits.CallDepth()
+	defer func() {
+		if taskErr := vm.exec.Finish(); err == nil && taskErr != nil {
+			result, err = nil, taskErr
+		}
+	}()
+	defer func() {
+		if r := recover(); r != nil {
+			vm.stack, vm.frames = vm.stack[:0], vm.frames[:0]
+			result, err = nil, lexer.RecoveredError(r)
+		}
+	}()
+	vm.stack = append(vm.stack[:0], &Closure{proto: proto})
+	vm.call(vm.stack[0].(*Closure), 0)
+	return vm.execute(), nil
+}
+
+// push 压栈
+func (vm *VM) push(v lexer.Value) {
+	vm.stack = append(vm.stack, v)
+}
+
+// pop 出栈
+func (vm *VM) pop() lexer.Value {
+	v := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+	return v
+}
+
+// call 以栈顶argc个实参进入闭包
+func (vm *VM) call(c *Closure, argc int) {
+	p := c.proto
+	base := len(vm.stack) - argc
+	for i := p.Params; i < p.Locals; i++ {
+		vm.push(undefined{})
+	}
+	var cells []*cell
+	if len(p.Cells) > 0 {
+		cells = make([]*cell, p.Locals)
+		for _, slot := range p.Cells {
+			cells[slot] = &cell{vm.stack[base+slot]}
+		}
+	}
+	vm.frames = append(vm.frames, frame{closure: c, base: base, cells: cells})
+}
+
+// execute 执行指令直到最外层的栈帧返回
+func (vm *VM) execute() lexer.Value {
+	f := &vm.frames[len(vm.frames)-1]
+	code := f.closure.proto.Code
+	for {
+		ins := code[f.pc]
+		f.pc++
+		switch arg := ins.Arg(); ins.Op() {
+		case compiler.OpConst:
+			vm.push(f.closure.proto.Constants[arg])
+		case compiler.OpNil:
+			vm.push(nil)
+		case compiler.OpTrue:
+			vm.push(true)
+		case compiler.OpFalse:
+			vm.push(false)
+		case compiler.OpPop:
+			vm.stack = vm.stack[:len(vm.stack)-1]
+		case compiler.OpDup:
+			vm.push(vm.stack[len(vm.stack)-1])
+		case compiler.OpSwap:
+			n := len(vm.stack)
+			vm.stack[n-1], vm.stack[n-2] = vm.stack[n-2], vm.stack[n-1]
+		case compiler.OpLoadLocal:
+			v := vm.stack[f.base+arg]
+			if _, ok := v.(undefined); ok {
+				vm.undefinedError(f)
+			}
+			vm.push(v)
+		case compiler.OpStoreLocal:
+			vm.stack[f.base+arg] = vm.pop()
+		case compiler.OpLoadCell:
+			v := f.cells[arg].value
+			if _, ok := v.(undefined); ok {
+				vm.undefinedError(f)
+			}
+			vm.push(v)
+		case compiler.OpStoreCell:
+			f.cells[arg].value = vm.pop()
+		case compiler.OpLoadFree:
+			v := f.closure.free[arg].value
+			if _, ok := v.(undefined); ok {
+				vm.undefinedError(f)
+			}
+			vm.push(v)
+		case compiler.OpStoreFree:
+			f.closure.free[arg].value = vm.pop()
+		case compiler.OpLoadGlobal:
+			name := f.closure.proto.Constants[arg].(string)
+			v, ok := vm.globals.Get(name)
+			if !ok {
+				panic(lexer.NewNameError(vm.node(f), name))
+			}
+			vm.push(v)
+		case compiler.OpStoreGlobal:
+			vm.globals.Put(f.closure.proto.Constants[arg].(string), vm.pop())
+		case compiler.OpDefineGlobal:
+			vm.globals.PutNew(f.closure.proto.Constants[arg].(string), vm.pop())
+		case compiler.OpBinary:
+			right := vm.pop()
+			left := vm.pop()
+			vm.push(vm.binary(f, arg, left, right))
+		case compiler.OpNeg:
+			vm.push(vm.node(f).(lexer.NegativeExprNode).Compute(vm.pop()))
+		case compiler.OpNot:
+			vm.push(!lexer.Truthy(vm.pop()))
+		case compiler.OpTruthy:
+			vm.push(lexer.Truthy(vm.pop()))
+		case compiler.OpJump:
+			if arg < f.pc {
+				// 向后跳转为循环的一次迭代
+				vm.exec.Step(vm.node(f))
+			}
+			f.pc = arg
+		case compiler.OpJumpIfFalse:
+			if !lexer.Truthy(vm.pop()) {
+				f.pc = arg
+			}
+		case compiler.OpJumpIfTrue:
+			if lexer.Truthy(vm.pop()) {
+				f.pc = arg
+			}
+		case compiler.OpCall:
+			fn := vm.stack[len(vm.stack)-arg-1]
+			switch fv := fn.(type) {
+			case *Closure:
+				if arg != fv.proto.Params {
+					panic(lexer.NewArityError(vm.node(f), fv.proto.Params, arg))
+				}
+				vm.exec.Step(vm.node(f))
+				// 最外层的栈帧为顶层代码
+				if len(vm.frames) > vm.depth {
+					panic(lexer.NewLimitExceeded(vm.node(f), "call depth", vm.depth))
+				}
+				vm.call(fv, arg)
+				f = &vm.frames[len(vm.frames)-1]
+				code = f.closure.proto.Code
+			case *lexer.NativeFunction:
+				args := make([]lexer.Value, arg)
+				copy(args, vm.stack[len(vm.stack)-arg:])
+				vm.stack = vm.stack[:len(vm.stack)-arg-1]
+				vm.push(vm.exec.CallNative(fv, vm.node(f), args))
+			default:
+				panic(lexer.NewTypeError(vm.node(f), "bad function"))
+			}
+		case compiler.OpReturn:
+			result := vm.pop()
+			vm.stack = vm.stack[:f.base-1]
+			vm.frames = vm.frames[:len(vm.frames)-1]
+			if len(vm.frames) == 0 {
+				return result
+			}
+			vm.push(result)
+			f = &vm.frames[len(vm.frames)-1]
+			code = f.closure.proto.Code
+		case compiler.OpClosure:
+			p := f.closure.proto.Constants[arg].(*compiler.Proto)
+			vm.exec.Alloc(vm.node(f), 1)
+			c := &Closure{proto: p, free: make([]*cell, len(p.Free))}
+			for i, fv := range p.Free {
+				if fv.Local {
+					c.free[i] = f.cells[fv.Index]
+				} else {
+					c.free[i] = f.closure.free[fv.Index]
+				}
+			}
+			vm.push(c)
+		case compiler.OpArray:
+			vm.exec.Alloc(vm.node(f), 1)
+			array := listOf(vm.stack[len(vm.stack)-arg:])
+			vm.stack = vm.stack[:len(vm.stack)-arg]
+			vm.push(array)
+		case compiler.OpMap:
+			vm.exec.Alloc(vm.node(f), 1)
+			m := vm.node(f).(lexer.MapLiteralNode).Build(vm.stack[len(vm.stack)-arg:])
+			vm.stack = vm.stack[:len(vm.stack)-arg]
+			vm.push(m)
+		case compiler.OpIndex:
+			index := vm.pop()
+			vm.push(vm.node(f).(lexer.ArrayRefNode).Get(vm.pop(), index))
+		case compiler.OpSlice:
+			to := vm.pop()
+			from := vm.pop()
+			vm.push(vm.node(f).(lexer.ArrayRefNode).GetSlice(vm.pop(), from, to))
+		case compiler.OpSetIndex:
+			value := vm.pop()
+			index := vm.pop()
+			vm.push(vm.node(f).(lexer.ArrayRefNode).Set(vm.pop(), index, value))
+		case compiler.OpDelIndex:
+			index := vm.pop()
+			vm.push(vm.node(f).(lexer.ArrayRefNode).Remove(vm.pop(), index))
+		case compiler.OpGetField:
+			vm.push(vm.node(f).(lexer.DotNode).Get(vm.pop()))
+		case compiler.OpSetField:
+			value := vm.pop()
+			vm.push(vm.node(f).(lexer.DotNode).Assign(nil, vm.pop(), value))
+		case compiler.OpDelField:
+			vm.push(vm.node(f).(lexer.DotNode).Delete(nil, vm.pop()))
+		default:
+			panic(lexer.NewRuntimeError(vm.node(f), "bad instruction: %v", ins))
+		}
+	}
+}
+
+// node 获取当前指令对应的语法树节点
+func (vm *VM) node(f *frame) lexer.TreeNode {
+	return f.closure.proto.Nodes[f.pc-1]
+}
+
+// undefinedError 读取未赋值的局部变量
+func (vm *VM) undefinedError(f *frame) {
+	v := vm.node(f).(lexer.VariableNode)
+	panic(lexer.NewNameError(v, v.Name()))
+}
+
+// binary 计算二元表达式, 整数的算术和比较直接计算, 其他情况与解释执行一致
+func (vm *VM) binary(f *frame, op int, left lexer.Value, right lexer.Value) lexer.Value {
+	if l, ok := left.(int); ok {
+		if r, ok := right.(int); ok {
+			switch op {
+			case compiler.BinAdd:
+				if s := l + r; (s > l) == (r > 0) {
+					return s
+				}
+			case compiler.BinSub:
+				if s := l - r; (s < l) == (r > 0) {
+					return s
+				}
+			case compiler.BinLt:
+				return l < r
+			case compiler.BinGt:
+				return l > r
+			case compiler.BinLe:
+				return l <= r
+			case compiler.BinGe:
+				return l >= r
+			case compiler.BinEq:
+				return l == r
+			case compiler.BinNe:
+				return l != r
+			}
+		}
+	}
+	result := vm.node(f).(lexer.BinaryExprNode).Compute(left, right)
+	if s, ok := result.(string); ok {
+		vm.exec.AllocString(vm.node(f), len(s))
+	}
+	return result
+}
+
+// listOf 以values创建数组
+func listOf(values []lexer.Value) *list.ArrayList {
+	array := list.New(len(values))
+	for _, v := range values {
+		array.Add(v)
+	}
+	return array
+}
@@ -0,0 +1,210 @@
+package vm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"simple-script-language/compiler"
+	"simple-script-language/lexer"
+)
+
+// workloads 比较解释执行语法树与虚拟机性能的脚本
+var workloads = []struct {
+	name string
+	src  string
+}{
+	{"fib", `
+def fib(n) { if n < 2 { n } else { fib(n - 1) + fib(n - 2) } }
+fib(20)
+`},
+	{"loop", `
+sum = 0
+i = 0
+while i < 100000 {
+  if i % 3 == 0 { sum = sum + i }
+  i = i + 1
+}
+sum
+`},
+	{"array", `
+a = range(100)
+sum = 0
+i = 0
+while i < 100000 {
+  a[i % 100] = a[i % 100] + 1
+  sum = sum + a[i % 100]
+  i = i + 1
+}
+sum
+`},
+	{"string", `
+s = ""
+i = 0
+while i < 2000 {
+  s = s + str(i % 10)
+  i = i + 1
+}
+len(upper(s))
+`},
+}
+
+// parse 解析源码
+func parse(tb testing.TB, src string) []lexer.TreeNode {
+	tb.Helper()
+	nodes, err := lexer.Parse("test.ssl", strings.NewReader(src))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return nodes
+}
+
+// compile 解析并编译源码
+func compile(tb testing.TB, src string) *compiler.Proto {
+	tb.Helper()
+	proto, err := compiler.Compile(parse(tb, src))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return proto
+}
+
+// outcome 一次执行的结果
+type outcome struct {
+	value  string
+	output string
+	kind   string // 运行时错误的类型, 没有错误时为空
+}
+
+// outcomeOf 以执行结果创建outcome
+func outcomeOf(value lexer.Value, err error, out *bytes.Buffer) outcome {
+	o := outcome{value: lexer.ToString(value), output: out.String()}
+	if err != nil {
+		o.value = ""
+		var re *lexer.RuntimeError
+		if errors.As(err, &re) {
+			o.kind = re.Kind
+		} else {
+			o.kind = err.Error()
+		}
+	}
+	return o
+}
+
+// evalTree 解释执行语法树
+func evalTree(t *testing.T, src string) outcome {
+	var out bytes.Buffer
+	value, err := lexer.Eval(parse(t, src), lexer.NewNestedEnvironment(lexer.NewBuiltinEnv(&out)))
+	return outcomeOf(value, err, &out)
+}
+
+// evalVM 在虚拟机中执行
+func evalVM(t *testing.T, src string) outcome {
+	var out bytes.Buffer
+	value, err := New(lexer.NewNestedEnvironment(lexer.NewBuiltinEnv(&out))).Run(compile(t, src))
+	return outcomeOf(value, err, &out)
+}
+
+func TestEquivalence(t *testing.T) {
+	tests := []string{
+		"1 + 2 * 3 - 4 / 2",
+		"7 % 3 + 7 / 2",
+		"9223372036854775807 + 1",
+		"pow(2, 100) / pow(2, 98)",
+		"1.5 * 2",
+		`"a" + 1 + 2.5`,
+		`"abc" < "abd"`,
+		"!true || 1 == 1 && nil == nil",
+		"x = 3\nx = x * x\nx",
+		"i = 0\ns = 0\nwhile i < 10 { i = i + 1\nif i % 2 == 0 { continue }\nif i > 7 { break }\ns = s + i }\ns",
+		"def f(n) { if n < 2 { return n }\nf(n - 1) + f(n - 2) }\nf(15)",
+		"def counter() { n = 0\nfun() { n = n + 1\nn } }\nc = counter()\nc()\nc()\nc()",
+		"def add(a) { fun(b) { a + b } }\nadd(2)(3)",
+		"a = [1, 2, 3]\na[0] = 10\na[-1] + a[0] + len(a)",
+		"a = [1, 2, 3, 4]\na[1:3]",
+		`m = {"k": 1, 2: "two"}` + "\nm.k = 5\ndel m[2]\nm",
+		`"k" in {"k": 1}`,
+		`2 in [1, 2]`,
+		`println("hi", [1, {"a": nil}])` + "\nprintf(\"%v-%v\\n\", 1, 2.5)",
+		`upper(join(split("a,b", ","), "-"))`,
+		"1 / 0",
+		"def f() { undefinedName + 1 }\nf()",
+		"[1][5]",
+		`{"a": 1}["b"]`,
+		`1 + "a" - 1`,
+		"def f(a, b) { a }\nf(1)",
+		`int("x")`,
+	}
+	for _, src := range tests {
+		tree, code := evalTree(t, src), evalVM(t, src)
+		if tree != code {
+			t.Errorf("%q:\ntree: %+v\nvm:   %+v", src, tree, code)
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		src    string
+		limits lexer.Limits
+		limit  string
+	}{
+		{"while true {}", lexer.Limits{MaxSteps: 100}, "step"},
+		{"def f(n) { f(n + 1) }\nf(0)", lexer.Limits{}, "call depth"},
+		{"def f(n) { f(n + 1) }\nf(0)", lexer.Limits{MaxCallDepth: 50}, "call depth"},
+		{"i = 0\nwhile true { a = [i]\ni = i + 1 }", lexer.Limits{MaxAllocations: 100}, "allocation"},
+		{"x = range(200000000)", lexer.Limits{MaxAllocations: 10}, "allocation"},
+		{"s = \"ab\"\ni = 0\nwhile i < 40 { s = s + s\ni = i + 1 }", lexer.Limits{MaxAllocations: 10}, "allocation"},
+	}
+	for _, tt := range tests {
+		_, err := New(newEnv()).RunContext(context.Background(), compile(t, tt.src), tt.limits)
+		var le *lexer.LimitExceeded
+		if !errors.As(err, &le) || le.Limit != tt.limit {
+			t.Errorf("%q: got %v, want %v limit exceeded", tt.src, err, tt.limit)
+		}
+	}
+}
+
+func TestCanceled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := New(newEnv()).RunContext(ctx, compile(t, "while true {}"), lexer.Limits{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want deadline exceeded", err)
+	}
+}
+
+// newEnv 创建不输出的全局环境
+func newEnv() lexer.Environment {
+	return lexer.NewNestedEnvironment(lexer.NewBuiltinEnv(ioutil.Discard))
+}
+
+func BenchmarkTree(b *testing.B) {
+	for _, w := range workloads {
+		nodes := parse(b, w.src)
+		b.Run(w.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := lexer.Eval(nodes, newEnv()); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkVM(b *testing.B) {
+	for _, w := range workloads {
+		proto := compile(b, w.src)
+		b.Run(w.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := New(newEnv()).Run(proto); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}